@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// activeWindow is a daily time range in local time, it may wrap past midnight.
+type activeWindow struct {
+	from time.Duration
+	to   time.Duration
+}
+
+var (
+	// activeWindows restrict the intrusive checks (-disk-check,
+	// -check-runtime-config and the smoke command) to these times of day.
+	activeWindows []*activeWindow
+	// activeMaxLoad skips the intrusive checks while the 1 minute load average is above it.
+	activeMaxLoad = 0.0
+)
+
+// activeWindowsFlag parses -active-windows, e.g. "01:00-05:00,22:00-02:00".
+type activeWindowsFlag struct{}
+
+func (activeWindowsFlag) String() string {
+	var values []string
+	for _, w := range activeWindows {
+		values = append(values, formatClock(w.from)+"-"+formatClock(w.to))
+	}
+	return strings.Join(values, ",")
+}
+
+func (activeWindowsFlag) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		bounds := strings.SplitN(entry, "-", 2)
+		if len(bounds) != 2 {
+			return fmt.Errorf("window %q is not HH:MM-HH:MM", entry)
+		}
+		from, err := parseClock(bounds[0])
+		if err != nil {
+			return err
+		}
+		to, err := parseClock(bounds[1])
+		if err != nil {
+			return err
+		}
+		activeWindows = append(activeWindows, &activeWindow{from: from, to: to})
+	}
+	return nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("time of day %q is not HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func (w *activeWindow) contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	clock := t.Sub(midnight)
+	if w.from <= w.to {
+		return clock >= w.from && clock < w.to
+	}
+	return clock >= w.from || clock < w.to
+}
+
+// activeChecksBlocked returns why the intrusive checks must not run now, or ""
+// if they may, load1 is the current 1 minute load average or negative if unknown.
+func activeChecksBlocked(now time.Time, load1 float64) string {
+	if len(activeWindows) > 0 {
+		inWindow := false
+		for _, w := range activeWindows {
+			inWindow = inWindow || w.contains(now)
+		}
+		if !inWindow {
+			return fmt.Sprintf("outside of -active-windows %s", activeWindowsFlag{}.String())
+		}
+	}
+	if activeMaxLoad > 0 && load1 > activeMaxLoad {
+		return fmt.Sprintf("load average %.2f is over -active-max-load %g", load1, activeMaxLoad)
+	}
+	return ""
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"net/http"
+	"os"
+	"text/template"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+var (
+	// relistThreshold is the relist SLO, the kubelet reports the PLEG unhealthy
+	// when a relist has not completed within 3 minutes.
+	relistThreshold = 3 * time.Minute
+	nodeName        = os.Getenv("NODE_NAME")
+
+	// alertFor is how long a check must breach its SLO before its alert fires,
+	// alertCooldown is how long it must be healthy again before it is resolved.
+	alertFor      = time.Duration(0)
+	alertCooldown = 5 * time.Minute
+
+	webhookURL      = ""
+	webhookTemplate = "slack"
+)
+
+type alertState string
+
+const (
+	alertInactive alertState = ""
+	alertPending  alertState = "pending"
+	alertFiring   alertState = "firing"
+	alertResolved alertState = "resolved"
+)
+
+// builtinWebhookTemplates are the payloads of well known chat webhooks.
+var builtinWebhookTemplates = map[string]string{
+	"slack": `{"text": {{printf "[%s] oncepleg on node %s: %s" .State .Node .Summary | json}}}`,
+	"teams": `{
+  "@type": "MessageCard",
+  "@context": "http://schema.org/extensions",
+  "themeColor": "{{if eq .State "firing"}}d63333{{else}}2eb886{{end}}",
+  "summary": {{.Summary | json}},
+  "title": {{printf "[%s] oncepleg on node %s" .State .Node | json}},
+  "text": {{.Summary | json}}
+}`,
+}
+
+// alert is the outcome of a check against its SLO. State is set by the alert
+// state machine, notifiers only see firing and resolved alerts.
+type alert struct {
+	Node      string
+	Check     string
+	Time      time.Time
+	State     alertState
+	Breached  bool
+	Since     time.Time
+	Duration  time.Duration
+	Threshold time.Duration
+	// Severity is one of severities, set by -thresholds.
+	Severity string
+	Pods     int
+	// Calls is the number of calls of the CRI method of a method check.
+	Calls int
+	Error string
+}
+
+// Summary describes the alert in one line.
+func (a *alert) Summary() string {
+	if a.State == alertResolved {
+		return fmt.Sprintf("%s recovered, breached since %s, last took %v", a.Check, a.Since.Format(time.RFC3339), a.Duration)
+	}
+	if a.Error != "" {
+		return fmt.Sprintf("%s failed after %v: %s", a.Check, a.Duration, a.Error)
+	}
+	if a.Check != "relist" {
+		return fmt.Sprintf("%s took up to %v in %d calls, threshold is %v", a.Check, a.Duration, a.Calls, a.Threshold)
+	}
+	return fmt.Sprintf("%s of %d pods took %v, threshold is %v", a.Check, a.Pods, a.Duration, a.Threshold)
+}
+
+type notifier interface {
+	notify(a *alert) error
+}
+
+var notifiers []notifier
+
+// setupNotifiers creates the notifiers enabled by flags.
+func setupNotifiers() error {
+	if nodeName == "" {
+		nodeName, _ = os.Hostname()
+	}
+	if webhookURL != "" {
+		n, err := newWebhookNotifier(webhookURL, webhookTemplate)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if smtpServer != "" {
+		n, err := newSMTPNotifier()
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if pagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, newPagerDutyNotifier())
+	}
+	return setupBreachHook()
+}
+
+// newRelistAlert returns the alert of a relist, which is breached if the relist failed or was slow.
+func newRelistAlert(now time.Time, elapsed time.Duration, pods int, err error) *alert {
+	threshold := relistThreshold
+	if err == nil {
+		threshold = learnThreshold("relist", relistThreshold, now, elapsed)
+	}
+	a := &alert{
+		Node:      nodeName,
+		Check:     "relist",
+		Time:      now,
+		Breached:  err != nil || (threshold > 0 && elapsed > threshold),
+		Duration:  elapsed,
+		Threshold: threshold,
+		Severity:  relistSeverity,
+		Pods:      pods,
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	return a
+}
+
+// alertTracker is the state machine of the alert of one check:
+// inactive -> pending -> firing -> resolved (inactive).
+type alertTracker struct {
+	state         alertState
+	breachedSince time.Time
+	healthySince  time.Time
+}
+
+var alertTrackers = make(map[string]*alertTracker)
+
+// observe advances the state machine with a new outcome of the check and
+// returns whether the alert changed to firing or resolved.
+func (t *alertTracker) observe(a *alert) bool {
+	if a.Breached {
+		t.healthySince = time.Time{}
+		switch t.state {
+		case alertInactive:
+			t.state, t.breachedSince = alertPending, a.Time
+			fallthrough
+		case alertPending:
+			if a.Time.Sub(t.breachedSince) < alertFor {
+				return false
+			}
+			t.state = alertFiring
+			return true
+		}
+		return false
+	}
+
+	switch t.state {
+	case alertPending:
+		t.state = alertInactive
+	case alertFiring:
+		if t.healthySince.IsZero() {
+			t.healthySince = a.Time
+		}
+		if a.Time.Sub(t.healthySince) >= alertCooldown {
+			t.state = alertResolved
+			return true
+		}
+	}
+	return false
+}
+
+// notify advances the alert state of the check and sends firing and resolved
+// alerts to all notifiers, so brief spikes do not notify anyone. The states
+// follow the times of the alerts, c only times the breach hook.
+func notify(c clock, a *alert) {
+	if a.Breached {
+		breaches = append(breaches, a)
+		klog.Warningf("SLO breached: %s", a.Summary())
+		runBreachHook(c, a)
+	}
+	t, found := alertTrackers[a.Check]
+	if !found {
+		t = &alertTracker{}
+		alertTrackers[a.Check] = t
+	}
+	if !t.observe(a) {
+		klog.V(4).Infof("Alert %s is %q", a.Check, t.state)
+		return
+	}
+	a.State, a.Since = t.state, t.breachedSince
+	if t.state == alertResolved {
+		t.state = alertInactive
+	}
+	klog.V(2).Infof("Alert %s is %s", a.Check, a.State)
+
+	for _, n := range notifiers {
+		if err := n.notify(a); err != nil {
+			klog.Errorf("Notify %T failed: %v", n, err)
+		}
+	}
+}
+
+type webhookNotifier struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+func newWebhookNotifier(url, tmpl string) (*webhookNotifier, error) {
+	text, found := builtinWebhookTemplates[tmpl]
+	if !found {
+		data, err := ioutil.ReadFile(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("webhook template %q is neither built-in nor readable: %v", tmpl, err)
+		}
+		text = string(data)
+	}
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookNotifier{
+		url:      url,
+		template: t,
+		client:   &http.Client{Timeout: notifyTimeout},
+	}, nil
+}
+
+func (n *webhookNotifier) notify(a *alert) error {
+	var body bytes.Buffer
+	if err := n.template.Execute(&body, a); err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.url, "application/json", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, msg)
+	}
+	return nil
+}
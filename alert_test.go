@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// recordingNotifier keeps the alerts sent to it.
+type recordingNotifier struct {
+	alerts []*alert
+}
+
+func (n *recordingNotifier) notify(a *alert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+// withNotifier resets the alert state for a test and records the
+// notifications, restore puts the state back.
+func withNotifier() (n *recordingNotifier, restore func()) {
+	n = &recordingNotifier{}
+	savedNotifiers, savedTrackers := notifiers, alertTrackers
+	savedFor, savedCooldown := alertFor, alertCooldown
+	savedThreshold, savedThresholds := relistThreshold, methodThresholds
+	notifiers, alertTrackers = []notifier{n}, make(map[string]*alertTracker)
+	methodThresholds = make(map[string]*methodThreshold)
+	return n, func() {
+		notifiers, alertTrackers = savedNotifiers, savedTrackers
+		alertFor, alertCooldown = savedFor, savedCooldown
+		relistThreshold, methodThresholds = savedThreshold, savedThresholds
+		breaches = nil
+	}
+}
+
+func TestRelistAlertThreshold(t *testing.T) {
+	_, restore := withNotifier()
+	defer restore()
+	relistThreshold = time.Second
+	now := newFakeClock().Now()
+	for _, c := range []struct {
+		elapsed  time.Duration
+		err      error
+		breached bool
+	}{
+		{500 * time.Millisecond, nil, false},
+		{time.Second, nil, false},
+		{2 * time.Second, nil, true},
+		{10 * time.Millisecond, errors.New("unavailable"), true},
+	} {
+		a := newRelistAlert(now, c.elapsed, 3, c.err)
+		if a.Breached != c.breached {
+			t.Errorf("relist of %v with error %v: breached %v, want %v", c.elapsed, c.err, a.Breached, c.breached)
+		}
+		if a.Time != now {
+			t.Errorf("alert time %v, want %v", a.Time, now)
+		}
+	}
+}
+
+func TestAlertTransitions(t *testing.T) {
+	n, restore := withNotifier()
+	defer restore()
+	relistThreshold = time.Second
+	alertFor, alertCooldown = time.Minute, 5*time.Minute
+	clock := newFakeClock()
+
+	steps := []struct {
+		step    time.Duration
+		elapsed time.Duration
+		// state is the state notified by this relist, "" if none.
+		state alertState
+	}{
+		{0, 2 * time.Second, ""},
+		{30 * time.Second, 2 * time.Second, ""},
+		{30 * time.Second, 2 * time.Second, alertFiring},
+		{30 * time.Second, 2 * time.Second, ""},
+		{30 * time.Second, 100 * time.Millisecond, ""},
+		{4 * time.Minute, 100 * time.Millisecond, ""},
+		{time.Minute, 100 * time.Millisecond, alertResolved},
+		{30 * time.Second, 100 * time.Millisecond, ""},
+	}
+	for i, s := range steps {
+		clock.Step(s.step)
+		sent := len(n.alerts)
+		notify(clock, newRelistAlert(clock.Now(), s.elapsed, 1, nil))
+		switch {
+		case s.state == "" && len(n.alerts) != sent:
+			t.Errorf("step %d: notified %s, want nothing", i, n.alerts[len(n.alerts)-1].State)
+		case s.state != "" && len(n.alerts) != sent+1:
+			t.Errorf("step %d: notified nothing, want %s", i, s.state)
+		case s.state != "" && n.alerts[sent].State != s.state:
+			t.Errorf("step %d: notified %s, want %s", i, n.alerts[sent].State, s.state)
+		}
+	}
+}
+
+func TestPendingAlertRecovers(t *testing.T) {
+	n, restore := withNotifier()
+	defer restore()
+	relistThreshold = time.Second
+	alertFor = time.Minute
+	clock := newFakeClock()
+
+	notify(clock, newRelistAlert(clock.Now(), 2*time.Second, 1, nil))
+	clock.Step(30 * time.Second)
+	notify(clock, newRelistAlert(clock.Now(), 100*time.Millisecond, 1, nil))
+	clock.Step(40 * time.Second)
+	notify(clock, newRelistAlert(clock.Now(), 2*time.Second, 1, nil))
+	if len(n.alerts) != 0 {
+		t.Fatalf("brief spikes notified %d alerts, want none", len(n.alerts))
+	}
+	if got := alertTrackers["relist"].state; got != alertPending {
+		t.Errorf("state %q, want %q", got, alertPending)
+	}
+}
+
+func TestMethodThresholds(t *testing.T) {
+	n, restore := withNotifier()
+	defer restore()
+	methodThresholds["ContainerStatus"] = &methodThreshold{Threshold: 100 * time.Millisecond, Severity: "critical"}
+	clock := newFakeClock()
+
+	notifyMethods(clock, map[string]*methodStats{
+		"ContainerStatus": {Calls: 10, Max: 50 * time.Millisecond},
+		"ListContainers":  {Calls: 1, Max: time.Minute},
+	})
+	if len(n.alerts) != 0 {
+		t.Fatalf("healthy methods notified %d alerts", len(n.alerts))
+	}
+
+	clock.Step(10 * time.Second)
+	notifyMethods(clock, map[string]*methodStats{
+		"ContainerStatus": {Calls: 10, Max: 200 * time.Millisecond},
+	})
+	if len(n.alerts) != 1 {
+		t.Fatalf("notified %d alerts, want 1", len(n.alerts))
+	}
+	a := n.alerts[0]
+	if a.Check != "ContainerStatus" || a.State != alertFiring || a.Severity != "critical" || a.Calls != 10 {
+		t.Errorf("alert %s %s %s in %d calls, want ContainerStatus firing critical in 10 calls", a.Check, a.State, a.Severity, a.Calls)
+	}
+	if !a.Time.Equal(clock.Now()) {
+		t.Errorf("alert time %v, want the clock %v", a.Time, clock.Now())
+	}
+
+	// A method not called by the relist counts as healthy.
+	clock.Step(alertCooldown)
+	notifyMethods(clock, map[string]*methodStats{})
+	clock.Step(alertCooldown)
+	notifyMethods(clock, map[string]*methodStats{})
+	if len(n.alerts) != 2 || n.alerts[1].State != alertResolved {
+		t.Fatalf("notified %d alerts, want firing then resolved", len(n.alerts))
+	}
+}
+
+func TestLearnThreshold(t *testing.T) {
+	savedFactor, savedBaselines := baselineFactor, baselines
+	defer func() { baselineFactor, baselines = savedFactor, savedBaselines }()
+	baselineFactor, baselines = 3, make(map[string][]latencySample)
+	clock := newFakeClock()
+
+	for i := 0; i < baselineMinSamples; i++ {
+		if got := learnThreshold("relist", time.Second, clock.Now(), 10*time.Millisecond); got != time.Second {
+			t.Fatalf("sample %d: threshold %v while learning, want the static one", i, got)
+		}
+		clock.Step(time.Minute)
+	}
+	if got := learnThreshold("relist", time.Second, clock.Now(), 10*time.Millisecond); got != 30*time.Millisecond {
+		t.Errorf("learned threshold %v, want 3 times the p95 of 10ms", got)
+	}
+
+	// The samples out of the window are forgotten.
+	clock.Step(baselineWindow + time.Hour)
+	if got := learnThreshold("relist", time.Second, clock.Now(), 10*time.Millisecond); got != time.Second {
+		t.Errorf("threshold %v after the window, want the static one", got)
+	}
+}
+
+func TestBaselineBound(t *testing.T) {
+	savedFactor, savedMax, savedBaselines := baselineFactor, baselineMaxSamples, baselines
+	defer func() { baselineFactor, baselineMaxSamples, baselines = savedFactor, savedMax, savedBaselines }()
+	baselineFactor, baselineMaxSamples, baselines = 3, 100, make(map[string][]latencySample)
+	clock := newFakeClock()
+
+	first := clock.Now()
+	for i := 0; i < 1000; i++ {
+		learnThreshold("relist", time.Second, clock.Now(), 10*time.Millisecond)
+		clock.Step(time.Second)
+	}
+	samples := baselines["relist"]
+	if len(samples) > baselineMaxSamples {
+		t.Fatalf("%d samples kept, want at most %d", len(samples), baselineMaxSamples)
+	}
+	// Thinning keeps the baseline spanning the window, in time order.
+	span := samples[len(samples)-1].Time.Sub(samples[0].Time)
+	if span < 900*time.Second {
+		t.Errorf("baseline spans %v of the %v learned", span, clock.Now().Sub(first))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time.Before(samples[i-1].Time) {
+			t.Fatalf("sample %d out of time order", i)
+		}
+	}
+	// The recent relists do not weigh more than the old ones.
+	middle := samples[0].Time.Add(span / 2)
+	older := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(middle) })
+	if newer := len(samples) - older; older*4 < newer*3 || newer*4 < older*3 {
+		t.Errorf("%d samples in the older half of the baseline and %d in the newer one, want them even", older, newer)
+	}
+}
+
+func TestSMTPRateLimitSendsRecoveries(t *testing.T) {
+	// An unreachable server makes every email sent fail, a suppressed one
+	// returns nil.
+	n := &smtpNotifier{server: "127.0.0.1:1", lastFiring: make(map[string]time.Time), suppressed: make(map[string]int)}
+	n.subject = template.Must(template.New("subject").Parse(smtpSubject))
+	now := newFakeClock().Now()
+	n.lastFiring["relist"] = now
+
+	if err := n.notify(&alert{Check: "relist", State: alertFiring, Time: now.Add(time.Minute)}); err != nil {
+		t.Errorf("repeated firing email sent: %v", err)
+	}
+	if n.suppressed["relist"] != 1 {
+		t.Errorf("%d emails suppressed, want 1", n.suppressed["relist"])
+	}
+	if err := n.notify(&alert{Check: "relist", State: alertResolved, Time: now.Add(2 * time.Minute)}); err == nil {
+		t.Error("recovery email suppressed")
+	}
+	if err := n.notify(&alert{Check: "ListContainers", State: alertFiring, Time: now.Add(time.Minute)}); err == nil {
+		t.Error("firing email of another check suppressed")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	// baselineFactor learns the thresholds from the node's own latencies, a
+	// check breaches at this multiple of its p95 over baselineWindow. Zero
+	// keeps the static thresholds, which are also used while learning.
+	baselineFactor     = 0.0
+	baselineWindow     = 24 * time.Hour
+	baselineMinSamples = 30
+	// baselineMaxSamples bounds the latencies of a check kept for learning. A
+	// baseline half full keeps the latencies evenly spaced over its span, so
+	// it still spans the window at a lower resolution without weighing the
+	// recent relists more. A latency takes 32 bytes, zero keeps them all.
+	baselineMaxSamples = 2000
+	// baselineFile persists the baselines, so a restarted watch does not
+	// learn again. It is rewritten at most every baselineSaveInterval.
+	baselineFile         = ""
+	baselineSaveInterval = time.Minute
+)
+
+// baselines are the latencies of every check over the trailing baselineWindow.
+var baselines = make(map[string][]latencySample)
+
+var (
+	// baselineStore is baselineFile, opened once before dropping privileges.
+	baselineStore    *os.File
+	lastBaselineSave time.Time
+)
+
+// learnThreshold returns the threshold of a check at t and then adds latency
+// to its baseline, so a spike does not raise its own threshold.
+func learnThreshold(check string, static time.Duration, t time.Time, latency time.Duration) time.Duration {
+	if baselineFactor <= 0 {
+		return static
+	}
+	samples := baselines[check]
+	// Samples are in time order, drop the ones out of the window.
+	i := sort.Search(len(samples), func(i int) bool { return t.Sub(samples[i].Time) <= baselineWindow })
+	samples = samples[i:]
+
+	threshold := static
+	if len(samples) >= baselineMinSamples {
+		latencies := make([]time.Duration, len(samples))
+		for i, s := range samples {
+			latencies[i] = s.Latency
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		p95 := latencies[(len(latencies)-1)*95/100]
+		threshold = time.Duration(float64(p95) * baselineFactor)
+	}
+	if baselineMaxSamples > 0 && len(samples) >= baselineMaxSamples/2 {
+		// Half of the samples spread evenly over the span.
+		spacing := 2 * t.Sub(samples[0].Time) / time.Duration(baselineMaxSamples)
+		if spacing <= 0 {
+			spacing = 1
+		}
+		if len(samples) >= baselineMaxSamples {
+			samples = thinSamples(samples, spacing)
+		}
+		if t.Sub(samples[len(samples)-1].Time) < spacing {
+			baselines[check] = samples
+			return threshold
+		}
+	}
+	baselines[check] = append(samples, latencySample{Time: t, Latency: latency})
+	return threshold
+}
+
+// thinSamples drops in place the samples closer than spacing to the previous
+// one kept, keeping the oldest.
+func thinSamples(samples []latencySample, spacing time.Duration) []latencySample {
+	n := 1
+	for _, s := range samples[1:] {
+		if s.Time.Sub(samples[n-1].Time) >= spacing {
+			samples[n] = s
+			n++
+		}
+	}
+	return samples[:n]
+}
+
+// loadBaselines opens baselineFile and reads the baselines saved by a
+// previous process, a missing or malformed file starts learning again.
+func loadBaselines() error {
+	if baselineFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(baselineFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	baselineStore = f
+	data, err := ioutil.ReadAll(f)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	loaded := make(map[string][]latencySample)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		klog.Warningf("Ignore malformed baselines %s: %v", baselineFile, err)
+		return nil
+	}
+	baselines = loaded
+	klog.V(2).Infof("Loaded the baselines of %d checks from %s", len(loaded), baselineFile)
+	return nil
+}
+
+// saveBaselines rewrites baselineFile at most every baselineSaveInterval.
+func saveBaselines(now time.Time) error {
+	if baselineStore == nil || baselineFactor <= 0 || now.Sub(lastBaselineSave) < baselineSaveInterval {
+		return nil
+	}
+	data, err := json.Marshal(baselines)
+	if err != nil {
+		return err
+	}
+	lastBaselineSave = now
+	return rewriteFile(baselineStore, data)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// userAgent is sent with every call, so the logs of the runtime tell the
+// probe traffic from the kubelet traffic.
+func userAgent() string {
+	return "oncepleg/" + version
+}
+
+// tagCall is a grpc.UnaryClientInterceptor marking every call as diagnostics
+// for the tracing of the runtime.
+func tagCall(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "purpose", "diagnostics")
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/coderwangke/oncepleg/internal/platform"
+	"k8s.io/klog"
+	"os"
+)
+
+var (
+	cgroupRoot = "/sys/fs/cgroup"
+	cgroups    = platform.NewCgroupInspector(cgroupRoot, procRoot)
+
+	// inspectCgroups enables the cgroup cross-check of containers.
+	inspectCgroups = false
+)
+
+// checkContainerCgroup verifies the cgroup of a container exists and looks sane,
+// because missing or frozen cgroups are another source of status-call stalls.
+func checkContainerCgroup(containerID string, running bool, info *containerInfo) {
+	cgroupsPath := ""
+	if info.RuntimeSpec != nil && info.RuntimeSpec.Linux != nil {
+		cgroupsPath = info.RuntimeSpec.Linux.CgroupsPath
+	}
+	cg, err := cgroups.Cgroup(cgroupsPath, info.Pid)
+	switch {
+	case err == platform.ErrUnsupported:
+		klog.V(4).Infof("Container %s cgroup can not be inspected: %v", containerID, err)
+		return
+	case cg == nil:
+		klog.V(2).Infof("Container %s has no known cgroup path", containerID)
+		return
+	case os.IsNotExist(err):
+		if running {
+			klog.Warningf("Container %s is running but its cgroup %s is missing: %v", containerID, cg.Dir, err)
+		}
+		return
+	case err != nil:
+		klog.Warningf("Container %s cgroup %s can not be read: %v", containerID, cg.Dir, err)
+		return
+	}
+	klog.V(4).Infof("Container %s cgroup: %s", containerID, cg.Dir)
+
+	switch {
+	case running && cg.Procs == 0:
+		klog.Warningf("Container %s is running but its cgroup %s has no processes", containerID, cg.Dir)
+	case !running && cg.Procs > 0:
+		klog.Warningf("Container %s is not running but its cgroup %s still has %d processes", containerID, cg.Dir, cg.Procs)
+	}
+
+	if cg.Frozen {
+		klog.Warningf("Container %s cgroup %s is frozen", containerID, cg.FreezerDir)
+	}
+}
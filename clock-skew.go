@@ -0,0 +1,30 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"time"
+)
+
+// clockSkewThreshold is how far in the future runtime timestamps may be before
+// they are reported, the runtime and the host may read the clock a bit apart.
+var clockSkewThreshold = 2 * time.Second
+
+// checkClockSkew compares the timestamps of a container with the host clock at
+// the time of its status call. Timestamps in the future mean the clock of the
+// runtime is ahead of ours, which corrupts every age and latency computed from them.
+func (rs *runtimeService) checkClockSkew(c *containerResult, now time.Time) {
+	for _, t := range []time.Time{c.CreatedAt, c.StartedAt, c.FinishedAt} {
+		if t.IsZero() {
+			continue
+		}
+		skew := t.Sub(now)
+		if skew <= clockSkewThreshold {
+			continue
+		}
+		klog.Warningf("Container %s has a timestamp %v in the future, the clocks of the runtime and the host are skewed", c.ID, skew.Round(time.Millisecond))
+		if skew > rs.clockSkew {
+			rs.clockSkew = skew
+		}
+		return
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "time"
+
+// clock is the time source of the measurements and timeouts, it can be
+// replaced by a fake one to drive the threshold, alert and timeout logic
+// deterministically.
+type clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) timer
+	Sleep(d time.Duration)
+}
+
+// timer is the part of time.Timer the timeouts use.
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// monotonicClock is the default clock. Durations between its times come from
+// the monotonic clock, so NTP adjustments of the wall clock do not skew them.
+type monotonicClock struct{}
+
+func (monotonicClock) Now() time.Time {
+	return time.Now()
+}
+
+// Since never goes negative, even for times without a monotonic reading
+// like the parsed ones, which fall back to the wall clock.
+func (monotonicClock) Since(t time.Time) time.Duration {
+	if d := time.Since(t); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (monotonicClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (monotonicClock) NewTimer(d time.Duration) timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (monotonicClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
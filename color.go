@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+var (
+	noColor = false
+
+	colorEnabled = false
+)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// setupColor colors the log when writing to a terminal. klog is switched to
+// writing headers into colorWriter, which colors lines by severity.
+func setupColor(flags *flag.FlagSet) {
+	if noColor || !isTerminal(os.Stderr) {
+		return
+	}
+	colorEnabled = true
+
+	skipHeaders := true
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "skip_headers" {
+			skipHeaders = f.Value.String() == "true"
+		}
+	})
+	flags.Set("skip_headers", "false")
+	flags.Set("logtostderr", "false")
+	// klog writes fatal logs to stderr by itself.
+	flags.Set("stderrthreshold", "FATAL")
+	// Every severity falls through to the info output, so it sees each line once.
+	klog.SetOutputBySeverity("INFO", &colorWriter{out: os.Stderr, skipHeaders: skipHeaders})
+	klog.SetOutputBySeverity("WARNING", ioutil.Discard)
+	klog.SetOutputBySeverity("ERROR", ioutil.Discard)
+	klog.SetOutputBySeverity("FATAL", ioutil.Discard)
+}
+
+// colorWriter colors klog lines by the severity in their header.
+type colorWriter struct {
+	out         io.Writer
+	skipHeaders bool
+}
+
+func (w *colorWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	line := p
+	if w.skipHeaders {
+		// "E1017 01:04:55.398136   11403 alert.go:176] message"
+		if i := bytes.Index(p, []byte("] ")); i >= 0 {
+			line = p[i+2:]
+		}
+	}
+	var err error
+	switch p[0] {
+	case 'F':
+		return len(p), nil
+	case 'E':
+		_, err = io.WriteString(w.out, colorRed+strings.TrimRight(string(line), "\n")+colorReset+"\n")
+	case 'W':
+		_, err = io.WriteString(w.out, colorYellow+strings.TrimRight(string(line), "\n")+colorReset+"\n")
+	default:
+		_, err = w.out.Write(line)
+	}
+	return len(p), err
+}
+
+func colorize(color, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// colorDuration colors a latency red above the threshold and yellow above half of it.
+func colorDuration(d, threshold time.Duration) string {
+	switch {
+	case threshold <= 0:
+		return d.String()
+	case d > threshold:
+		return colorize(colorRed, d.String())
+	case d > threshold/2:
+		return colorize(colorYellow, d.String())
+	}
+	return d.String()
+}
+
+// colorState colors ready and running states green.
+func colorState(state string) string {
+	switch state {
+	case "SANDBOX_READY", "CONTAINER_RUNNING":
+		return colorize(colorGreen, state)
+	case "CONTAINER_UNKNOWN":
+		return colorize(colorYellow, state)
+	}
+	return state
+}
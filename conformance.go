@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// conformanceResult is the outcome of calling one read-only CRI method.
+type conformanceResult struct {
+	Method    string  `json:"method"`
+	Result    string  `json:"result"`
+	Latency   float64 `json:"latency"`
+	LatencyNs int64   `json:"latencyNs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+const (
+	conformancePass          = "pass"
+	conformanceFail          = "fail"
+	conformanceUnimplemented = "unimplemented"
+	conformanceUnsupported   = "unsupported"
+)
+
+// conformanceCheck calls a CRI method, call is nil if the method is not in
+// the CRI version of this build.
+type conformanceCheck struct {
+	method string
+	call   func(ctx context.Context) error
+}
+
+// conformanceChecks are the read-only CRI methods exercised by the
+// conformance command.
+func (rs *runtimeService) conformanceChecks() []conformanceCheck {
+	return []conformanceCheck{
+		{"Version", func(ctx context.Context) error {
+			_, err := rs.Client.Version(ctx, &runtimeapi.VersionRequest{})
+			return err
+		}},
+		{"Status", func(ctx context.Context) error {
+			_, err := rs.Client.Status(ctx, &runtimeapi.StatusRequest{})
+			return err
+		}},
+		{"ListPodSandbox", func(ctx context.Context) error {
+			_, err := rs.Client.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{})
+			return err
+		}},
+		{"ListContainers", func(ctx context.Context) error {
+			_, err := rs.Client.ListContainers(ctx, &runtimeapi.ListContainersRequest{})
+			return err
+		}},
+		{"ListImages", func(ctx context.Context) error {
+			_, err := rs.Images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+			return err
+		}},
+		{"ImageFsInfo", func(ctx context.Context) error {
+			_, err := rs.Images.ImageFsInfo(ctx, &runtimeapi.ImageFsInfoRequest{})
+			return err
+		}},
+		{"ListContainerStats", func(ctx context.Context) error {
+			_, err := rs.Client.ListContainerStats(ctx, &runtimeapi.ListContainerStatsRequest{})
+			return err
+		}},
+		// ListPodSandboxStats is not part of CRI v1alpha2.
+		{"ListPodSandboxStats", nil},
+	}
+}
+
+// conformance calls every read-only CRI method once and prints which ones
+// the runtime implements, failing if any of them returns an error.
+func conformance(rs *runtimeService) error {
+	var results []*conformanceResult
+	failed := 0
+	for _, check := range rs.conformanceChecks() {
+		result := &conformanceResult{Method: check.method}
+		results = append(results, result)
+		if check.call == nil {
+			result.Result = conformanceUnsupported
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+		now := rs.Clock.Now()
+		err := check.call(ctx)
+		elapsed := rs.Clock.Since(now)
+		cancel()
+		result.Latency, result.LatencyNs = durationValue(elapsed), int64(elapsed)
+		switch {
+		case err == nil:
+			result.Result = conformancePass
+		case grpcstatus.Code(err) == codes.Unimplemented:
+			result.Result = conformanceUnimplemented
+		default:
+			result.Result = conformanceFail
+			result.Error = err.Error()
+			failed++
+		}
+	}
+
+	if err := writeConformance(os.Stdout, results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d CRI methods failed", failed)
+	}
+	return nil
+}
+
+func writeConformance(out io.Writer, results []*conformanceResult) error {
+	if output == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tRESULT\tLATENCY\tERROR")
+	for _, r := range results {
+		latency := "-"
+		if r.Result != conformanceUnsupported {
+			latency = formatDuration(time.Duration(r.LatencyNs))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Method, r.Result, latency, r.Error)
+	}
+	return w.Flush()
+}
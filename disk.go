@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// diskCheck enables the small-write/fsync latency check on runtime directories.
+	diskCheck        = false
+	diskCheckDirs    = ""
+	diskCheckSamples = 20
+)
+
+// runtimeStateDirs are the state and root directories of known runtimes.
+var runtimeStateDirs = map[string][]string{
+	"containerd": {"/var/lib/containerd", "/run/containerd"},
+	"crio":       {"/var/lib/containers/storage", "/var/lib/crio", "/run/containers/storage"},
+	"dockerd":    {"/var/lib/docker", "/var/lib/dockershim", "/run/docker"},
+}
+
+// diskLatency is the result of the write/fsync check of one directory.
+type diskLatency struct {
+	Dir     string
+	Samples int
+	P50     time.Duration
+	Max     time.Duration
+	Avg     time.Duration
+}
+
+// getDiskCheckDirs returns the directories to check, either the configured ones or
+// the existing state directories of the runtime daemon (all known runtimes if it was not found).
+func getDiskCheckDirs(comm string) []string {
+	if diskCheckDirs != "" {
+		return strings.Split(diskCheckDirs, ",")
+	}
+
+	var candidates []string
+	if dirs, found := runtimeStateDirs[comm]; found {
+		candidates = dirs
+	} else {
+		for _, dirs := range runtimeStateDirs {
+			candidates = append(candidates, dirs...)
+		}
+		sort.Strings(candidates)
+	}
+
+	var dirs []string
+	for _, dir := range candidates {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// checkDiskLatency writes and fsyncs a small block in dir samples times, like
+// boltdb does for every transaction.
+func checkDiskLatency(c clock, dir string, samples int) (*diskLatency, error) {
+	f, err := ioutil.TempFile(dir, ".oncepleg-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	block := make([]byte, 4096)
+	durations := make([]time.Duration, 0, samples)
+	var total time.Duration
+	for i := 0; i < samples; i++ {
+		now := c.Now()
+		if _, err := f.WriteAt(block, int64(i*len(block))); err != nil {
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			return nil, err
+		}
+		elapsed := c.Since(now)
+		durations = append(durations, elapsed)
+		total += elapsed
+	}
+	if len(durations) == 0 {
+		return nil, fmt.Errorf("no samples")
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	return &diskLatency{
+		Dir:     dir,
+		Samples: len(durations),
+		P50:     durations[len(durations)/2],
+		Max:     durations[len(durations)-1],
+		Avg:     total / time.Duration(len(durations)),
+	}, nil
+}
+
+// runDiskCheck measures the write/fsync latency of the runtime directories.
+func runDiskCheck(c clock, comm string) {
+	dirs := getDiskCheckDirs(comm)
+	if len(dirs) == 0 {
+		klog.Warningf("No runtime state directory found for the disk check")
+		return
+	}
+	for _, dir := range dirs {
+		l, err := checkDiskLatency(c, dir, diskCheckSamples)
+		if err != nil {
+			klog.Errorf("Disk check of %s failed: %v", dir, err)
+			continue
+		}
+		klog.V(2).Infof("Disk %s write+fsync latency, samples: %d, p50: %v, avg: %v, max: %v", l.Dir, l.Samples, l.P50, l.Avg, l.Max)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// dryRun prints the CRI calls instead of making them.
+var dryRun = false
+
+// plannedCall is a CRI call of the relist as dryRun prints it.
+type plannedCall struct {
+	method string
+	args   string
+	note   string
+}
+
+// planRelist returns the CRI calls a relist makes with the current flags, in
+// order. The filters are built by the same functions the relist uses.
+func planRelist() (calls, perPod, after []plannedCall) {
+	calls = []plannedCall{
+		{"ListPodSandbox", kubeletSandboxFilter("", true).String(), "every sandbox"},
+		{"ListContainers", kubeletContainerFilter("", true).String(), "every container"},
+	}
+	if parallelList {
+		calls[1].note += ", concurrently with ListPodSandbox"
+	}
+
+	hedged := ""
+	if hedgeAfter > 0 {
+		hedged = fmt.Sprintf(", hedged after %v", hedgeAfter)
+	}
+	perPod = []plannedCall{
+		{"ListPodSandbox", kubeletSandboxFilter("<pod uid>", true).String(), "the sandboxes of the pod"},
+		{"PodSandboxStatus", "PodSandboxId:<id>", "for each of them" + hedged},
+		{"ListContainers", kubeletContainerFilter("<pod uid>", true).String(), "the containers of the pod"},
+		{"ContainerStatus", fmt.Sprintf("ContainerId:<id>,Verbose:%v", verboseStatus()), "for each of them" + hedged},
+	}
+
+	after = []plannedCall{{"Version", "", "once per session"}}
+	if checkPodDirs {
+		after = append(after, plannedCall{"ListPodSandbox", kubeletSandboxFilter("", true).String(), "-check-pod-dirs"})
+	}
+	if inspectNamespaces {
+		after = append(after, plannedCall{"PodSandboxStatus", "PodSandboxId:<id>,Verbose:true", "for each ready sandbox, -inspect-namespaces"})
+	}
+	if checkRuntimeConfig {
+		note := "-check-runtime-config"
+		if readOnly && !readOnlyMethods["UpdateRuntimeConfig"] {
+			note += ", rejected by -read-only"
+		}
+		cidr := podCIDR
+		if cidr == "" {
+			cidr = "<podCIDR of " + kubeletConfig + ">"
+		}
+		after = append(after, plannedCall{"UpdateRuntimeConfig", "PodCidr:" + cidr, note})
+	}
+	return calls, perPod, after
+}
+
+// writeRelistPlan writes the CRI calls of planRelist without connecting to the
+// runtime, for reviewing what a run will do before granting it the socket.
+func writeRelistPlan(out io.Writer) error {
+	calls, perPod, after := planRelist()
+	fmt.Fprintf(out, "Endpoint: %s, timeout %v per call\n", remoteRuntimeEndpoint, runtimeRequestTimeout)
+	fmt.Fprintf(out, "User-Agent: %s, metadata purpose=diagnostics\n", userAgent())
+	if watchInterval > 0 {
+		fmt.Fprintf(out, "Every %v (-watch):\n", watchInterval)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	n := 0
+	section := func(title string, calls []plannedCall) {
+		if title != "" {
+			fmt.Fprintln(w, title)
+		}
+		for _, c := range calls {
+			n++
+			fmt.Fprintf(w, "%2d. %s\t%s\t%s\n", n, c.method, c.args, c.note)
+		}
+	}
+	section("", calls)
+	note := ""
+	if podStatusTimeout > 0 {
+		note = fmt.Sprintf(", abandoned after %v", podStatusTimeout)
+	}
+	section("For each pod, one after another"+note+":", perPod)
+	section("After the relist:", after)
+	return w.Flush()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// relistCount numbers the relists of the session, the timeline tells which
+// relist detected every event.
+var relistCount = 0
+
+// newTimeline returns the events as in the report, for /events.
+func newTimeline(events []*plegEvent) []*eventReport {
+	timeline := []*eventReport{}
+	for _, e := range events {
+		timeline = append(timeline, newEventReport(e))
+	}
+	return timeline
+}
+
+func printEventTimeline(out io.Writer, timeline []*eventReport) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tRELIST\tEVENT\tPOD ID\tCONTAINER ID")
+	for _, e := range timeline {
+		fmt.Fprintf(w, "%s\t#%d\t%s\t%s\t%s\n", e.Time, e.Relist, e.Type, e.PodID, e.ContainerID)
+	}
+	w.Flush()
+}
+
+// serveEvents serves the timeline of the recent events of the watch, oldest
+// first, as a table or as JSON with ?output=json.
+func serveEvents(w http.ResponseWriter, r *http.Request) {
+	timeline := newTimeline(getRecentEvents(maxRecentEvents))
+	if r.URL.Query().Get("output") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timeline)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	printEventTimeline(w, timeline)
+}
+
+// eventsCommand prints the timeline of a watch serving -listen on this node,
+// for reconstructing the sequence of an incident.
+func eventsCommand(addr string) error {
+	if addr == "" {
+		return fmt.Errorf("the events command needs the -listen address of the watch, e.g. -listen :9090")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	url := "http://" + net.JoinHostPort(host, port) + "/events"
+	if output == "json" {
+		url += "?output=json"
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s: %s", url, resp.Status, body)
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
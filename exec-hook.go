@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"k8s.io/klog"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+var (
+	// onBreachExec is the argv of a command run whenever a check breaches its
+	// SLO, every argument is a template, e.g. capture.sh {{.RPC}} {{.Duration}}.
+	onBreachExec    []string
+	onBreachTimeout = time.Minute
+)
+
+var (
+	breachHookArgs    []*template.Template
+	breachHookRunning int32
+	breachHooks       sync.WaitGroup
+)
+
+// RPC is the CRI method whose budget was violated, or "relist" for the whole relist.
+func (a *alert) RPC() string {
+	return a.Check
+}
+
+// breachExecFlag appends an argument to onBreachExec, arguments are never
+// split so they may contain spaces.
+type breachExecFlag struct{}
+
+func (breachExecFlag) String() string {
+	return strings.Join(onBreachExec, " ")
+}
+
+func (breachExecFlag) Set(value string) error {
+	onBreachExec = append(onBreachExec, value)
+	return nil
+}
+
+// setupBreachHook parses every argument of onBreachExec as a template. A
+// command without template is looked up now, a templated one once rendered.
+func setupBreachHook() error {
+	for i, arg := range onBreachExec {
+		t, err := template.New("arg").Parse(arg)
+		if err != nil {
+			return err
+		}
+		breachHookArgs = append(breachHookArgs, t)
+		if i == 0 && !strings.Contains(arg, "{{") {
+			if _, err := exec.LookPath(arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runBreachHook runs the breach hook in the background, unless the previous one
+// is still running, so a sustained breach does not pile up processes.
+func runBreachHook(c clock, a *alert) {
+	if len(breachHookArgs) == 0 {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&breachHookRunning, 0, 1) {
+		klog.V(2).Infof("Breach hook is still running, skip it for %s", a.Check)
+		return
+	}
+
+	args := make([]string, 0, len(breachHookArgs))
+	for _, t := range breachHookArgs {
+		var arg bytes.Buffer
+		if err := t.Execute(&arg, a); err != nil {
+			klog.Errorf("Render breach hook argument failed: %v", err)
+			atomic.StoreInt32(&breachHookRunning, 0)
+			return
+		}
+		args = append(args, arg.String())
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		klog.Errorf("Breach hook %q can not run: %v", args[0], err)
+		atomic.StoreInt32(&breachHookRunning, 0)
+		return
+	}
+
+	breachHooks.Add(1)
+	go func() {
+		defer breachHooks.Done()
+		defer atomic.StoreInt32(&breachHookRunning, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), onBreachTimeout)
+		defer cancel()
+
+		now := c.Now()
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			klog.Errorf("Breach hook %q failed after %v: %v, output: %s", strings.Join(args, " "), c.Since(now), err, out)
+			return
+		}
+		klog.V(2).Infof("Breach hook %q finished in %v", strings.Join(args, " "), c.Since(now))
+		klog.V(4).Infof("Breach hook output: %s", out)
+	}()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBreachHookArguments(t *testing.T) {
+	saved, savedArgs := onBreachExec, breachHookArgs
+	defer func() { onBreachExec, breachHookArgs = saved, savedArgs }()
+
+	onBreachExec, breachHookArgs = []string{"/no/such/capture.sh"}, nil
+	if err := setupBreachHook(); err == nil {
+		t.Error("missing command accepted")
+	}
+	// A templated command is only looked up once rendered.
+	onBreachExec, breachHookArgs = []string{"{{if .Breached}}/bin/sh{{end}}"}, nil
+	if err := setupBreachHook(); err != nil {
+		t.Errorf("templated command rejected: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "oncepleg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	onBreachExec, breachHookArgs = []string{"/bin/sh", "-c", `printf %s "$1" > "$2"`, "sh", "{{.RPC}} took {{.Duration}}", out}, nil
+	if err := setupBreachHook(); err != nil {
+		t.Fatal(err)
+	}
+	runBreachHook(newFakeClock(), &alert{Check: "ListContainers", Duration: 3e9, Breached: true})
+	breachHooks.Wait()
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "ListContainers took 3s"; got != want {
+		t.Errorf("hook got argument %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// filterRounds is the number of times the filter-efficacy command repeats
+// its measurement, the latencies are averaged.
+var filterRounds = 3
+
+// filterEfficacyReport compares listing the containers of every pod with the
+// pod UID label filter, like the kubelet does, with listing all containers
+// once and filtering them in the client.
+type filterEfficacyReport struct {
+	Containers   int    `json:"containers"`
+	Bytes        int    `json:"bytes"`
+	Pods         int    `json:"pods"`
+	Rounds       int    `json:"rounds"`
+	DurationUnit string `json:"durationUnit"`
+	// Unfiltered is one ListContainers without filter.
+	Unfiltered   float64 `json:"unfiltered"`
+	UnfilteredNs int64   `json:"unfilteredNs"`
+	// ClientSide is filtering the unfiltered list by every pod UID.
+	ClientSide   float64 `json:"clientSide"`
+	ClientSideNs int64   `json:"clientSideNs"`
+	// ServerSide is one ListContainers with the label filter per pod.
+	ServerSide   float64 `json:"serverSide"`
+	ServerSideNs int64   `json:"serverSideNs"`
+	// Ratio is ServerSide over Unfiltered plus ClientSide, above 1 when the
+	// filtered calls are more expensive.
+	Ratio float64 `json:"ratio"`
+}
+
+func (rs *runtimeService) listContainersWith(filter *runtimeapi.ContainerFilter) ([]*runtimeapi.Container, int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	now := rs.Clock.Now()
+	resp, err := rs.Client.ListContainers(ctx, &runtimeapi.ListContainersRequest{Filter: filter})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return resp.Containers, resp.Size(), rs.Clock.Since(now), nil
+}
+
+// filterEfficacy measures whether the per-pod filtered listing of the kubelet
+// is actually cheaper on this runtime than one unfiltered list.
+func filterEfficacy(rs *runtimeService) error {
+	if filterRounds < 1 {
+		return fmt.Errorf("-filter-rounds must be at least 1")
+	}
+	r := &filterEfficacyReport{Rounds: filterRounds, DurationUnit: durationUnit}
+	var unfiltered, clientSide, serverSide time.Duration
+	disagreements := 0
+	for round := 0; round < filterRounds; round++ {
+		containers, size, elapsed, err := rs.listContainersWith(kubeletContainerFilter("", true))
+		if err != nil {
+			return err
+		}
+		unfiltered += elapsed
+
+		now := rs.Clock.Now()
+		uids := make(map[string][]*runtimeapi.Container)
+		for _, c := range containers {
+			if uid := c.Labels[KubernetesPodUIDLabel]; uid != "" {
+				uids[uid] = append(uids[uid], c)
+			}
+		}
+		clientSide += rs.Clock.Since(now)
+		r.Containers, r.Bytes, r.Pods = len(containers), size, len(uids)
+
+		var sorted []string
+		for uid := range uids {
+			sorted = append(sorted, uid)
+		}
+		sort.Strings(sorted)
+		for _, uid := range sorted {
+			filtered, _, elapsed, err := rs.listContainersWith(kubeletContainerFilter(uid, true))
+			if err != nil {
+				return err
+			}
+			serverSide += elapsed
+			if len(filtered) != len(uids[uid]) {
+				disagreements++
+			}
+		}
+	}
+	rounds := time.Duration(filterRounds)
+	unfiltered, clientSide, serverSide = unfiltered/rounds, clientSide/rounds, serverSide/rounds
+	r.Unfiltered, r.UnfilteredNs = durationValue(unfiltered), int64(unfiltered)
+	r.ClientSide, r.ClientSideNs = durationValue(clientSide), int64(clientSide)
+	r.ServerSide, r.ServerSideNs = durationValue(serverSide), int64(serverSide)
+	if unfiltered+clientSide > 0 {
+		r.Ratio = float64(serverSide) / float64(unfiltered+clientSide)
+	}
+	if disagreements > 0 {
+		klog.Warningf("%d filtered lists disagree with the unfiltered list, containers changed while measuring or the runtime filters wrongly", disagreements)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printFilterEfficacy(os.Stdout, r)
+	return nil
+}
+
+func printFilterEfficacy(out io.Writer, r *filterEfficacyReport) {
+	fmt.Fprintf(out, "Containers: %d (%.1fKiB), pods: %d, average of %d rounds\n\n", r.Containers, float64(r.Bytes)/(1<<10), r.Pods, r.Rounds)
+	perPod := func(ns int64) string {
+		if r.Pods == 0 {
+			return "-"
+		}
+		return formatDuration(time.Duration(ns / int64(r.Pods)))
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tCALLS\tTOTAL\tPER POD")
+	fmt.Fprintf(w, "Unfiltered ListContainers\t1\t%s\t%s\n", formatDuration(time.Duration(r.UnfilteredNs)), perPod(r.UnfilteredNs))
+	fmt.Fprintf(w, "Client-side filtering\t0\t%s\t%s\n", formatDuration(time.Duration(r.ClientSideNs)), perPod(r.ClientSideNs))
+	fmt.Fprintf(w, "Filtered ListContainers\t%d\t%s\t%s\n", r.Pods, formatDuration(time.Duration(r.ServerSideNs)), perPod(r.ServerSideNs))
+	w.Flush()
+	fmt.Fprintln(out)
+	switch {
+	case r.Pods == 0:
+		fmt.Fprintln(out, "No pods to compare.")
+	case r.Ratio > 1:
+		fmt.Fprintf(out, "Listing per pod with the label filter is %.1fx slower than listing once and filtering in the client.\n", r.Ratio)
+	default:
+		fmt.Fprintf(out, "Listing per pod with the label filter is cheaper, %.0f%% of listing once and filtering in the client.\n", r.Ratio*100)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/klog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// findName matches the names of the containers and sandboxes found by
+	// the find command, the name of a sandbox is the name of its pod.
+	findName = ""
+	// findImage matches the images of the containers found by the find
+	// command, sandboxes have none and never match.
+	findImage = ""
+	// findLabels match the labels of the containers and sandboxes found by
+	// the find command, all of them must match.
+	findLabels []*labelMatcher
+)
+
+// labelMatcher matches the label Key by the regexp Value.
+type labelMatcher struct {
+	Key   string
+	Value *regexp.Regexp
+}
+
+type labelMatchersFlag struct{}
+
+func (labelMatchersFlag) String() string {
+	var values []string
+	for _, m := range findLabels {
+		values = append(values, m.Key+"="+m.Value.String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (labelMatchersFlag) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("label %q is not key=regexp", value)
+	}
+	re, err := regexp.Compile(kv[1])
+	if err != nil {
+		return fmt.Errorf("label %s: %v", kv[0], err)
+	}
+	findLabels = append(findLabels, &labelMatcher{Key: kv[0], Value: re})
+	return nil
+}
+
+// nodeItem is a container or sandbox of the node with the context of its pod.
+type nodeItem struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	PodUID    string `json:"podUID"`
+	// SandboxID is the sandbox of a container, empty for sandboxes.
+	SandboxID string `json:"sandboxID,omitempty"`
+	State     string `json:"state"`
+	// Image is the first tag of the image of a container, or else its
+	// digest or ID.
+	Image     string            `json:"image,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Labels    map[string]string `json:"labels,omitempty"`
+
+	// images are every tag, digest and ID of the image, matched by -image.
+	images []string
+}
+
+// listNodeItems lists every sandbox and container of the node, the pod of a
+// container comes from its sandbox or else from its labels, its image from
+// ListImages.
+func (rs *runtimeService) listNodeItems() ([]*nodeItem, error) {
+	images, _, err := rs.listImages()
+	if err != nil {
+		klog.Warningf("List images failed, matching the image IDs of the containers: %v", err)
+	}
+	refs := imageRefs(images)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	sandboxes, err := rs.Client.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{Filter: kubeletSandboxFilter("", true)})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := rs.Client.ListContainers(ctx, &runtimeapi.ListContainersRequest{Filter: kubeletContainerFilter("", true)})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*nodeItem
+	pods := make(map[string]*nodeItem, len(sandboxes.Items))
+	for _, s := range sandboxes.Items {
+		item := &nodeItem{
+			Kind:      "sandbox",
+			ID:        s.Id,
+			State:     s.State.String(),
+			CreatedAt: time.Unix(0, s.CreatedAt),
+			Labels:    s.Labels,
+		}
+		if s.Metadata != nil {
+			item.Name, item.Namespace, item.Pod, item.PodUID = s.Metadata.Name, s.Metadata.Namespace, s.Metadata.Name, s.Metadata.Uid
+		}
+		pods[s.Id] = item
+		items = append(items, item)
+	}
+	for _, c := range containers.Containers {
+		item := &nodeItem{
+			Kind:      "container",
+			ID:        c.Id,
+			SandboxID: c.PodSandboxId,
+			State:     c.State.String(),
+			Image:     c.GetImage().GetImage(),
+			CreatedAt: time.Unix(0, c.CreatedAt),
+			Labels:    c.Labels,
+		}
+		if c.Metadata != nil {
+			item.Name = c.Metadata.Name
+		}
+		item.images = []string{item.Image}
+		if img := refs.resolve(c); img != nil {
+			item.images = append(append(append([]string(nil), img.RepoTags...), img.RepoDigests...), img.Id)
+			item.Image = item.images[0]
+		}
+		if pod, ok := pods[c.PodSandboxId]; ok {
+			item.Namespace, item.Pod, item.PodUID = pod.Namespace, pod.Pod, pod.PodUID
+		} else {
+			item.Namespace, item.Pod, item.PodUID = c.Labels[KubernetesPodNamespaceLabel], c.Labels[KubernetesPodNameLabel], c.Labels[KubernetesPodUIDLabel]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// matches tells whether the item matches the regexps of the find command.
+func (item *nodeItem) matches(name, image *regexp.Regexp) bool {
+	if name != nil && !name.MatchString(item.Name) {
+		return false
+	}
+	if image != nil && (item.Kind != "container" || !matchesAny(image, item.images)) {
+		return false
+	}
+	for _, m := range findLabels {
+		value, ok := item.Labels[m.Key]
+		if !ok || !m.Value.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+func matchesAny(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// find searches the containers and sandboxes of the node by their names,
+// images and labels, and prints them with their pods.
+func find(rs *runtimeService) error {
+	name, err := compileOptional(findName)
+	if err != nil {
+		return fmt.Errorf("-name: %v", err)
+	}
+	image, err := compileOptional(findImage)
+	if err != nil {
+		return fmt.Errorf("-image: %v", err)
+	}
+	items, err := rs.listNodeItems()
+	if err != nil {
+		return err
+	}
+	found := []*nodeItem{}
+	for _, item := range items {
+		if item.matches(name, image) {
+			found = append(found, item)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Namespace != found[j].Namespace {
+			return found[i].Namespace < found[j].Namespace
+		}
+		return found[i].Pod < found[j].Pod
+	})
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+	printNodeItems(found)
+	return nil
+}
+
+func printNodeItems(items []*nodeItem) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tID\tNAME\tNAMESPACE\tPOD\tPOD UID\tSTATE\tIMAGE\tCREATED")
+	for _, i := range items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i.Kind, i.ID, i.Name, i.Namespace, i.Pod, i.PodUID, colorState(i.State), i.Image, formatTime(i.CreatedAt))
+	}
+	w.Flush()
+}
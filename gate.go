@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// readyFile is written by the gate command once the runtime is responsive.
+	readyFile = "/run/oncepleg/ready"
+	// gateTimeout bounds the gate command, including a hanging relist.
+	gateTimeout       = 5 * time.Minute
+	gateRetryInterval = 2 * time.Second
+)
+
+// gate relists until a relist succeeds within relistThreshold and writes
+// readyFile, for an init container holding back workloads after a node reboot
+// until the runtime is responsive. It fails after gateTimeout.
+func gate(rs *runtimeService) error {
+	// A marker left from before the reboot must not let pods through.
+	if err := os.Remove(readyFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	deadline := rs.Clock.After(gateTimeout)
+	for attempt := 1; ; attempt++ {
+		done := make(chan *relistResult, 1)
+		go func() {
+			done <- relist(rs)
+		}()
+		var result *relistResult
+		select {
+		case <-deadline:
+			return fmt.Errorf("runtime not responsive within %v, relist %d is still running", gateTimeout, attempt)
+		case result = <-done:
+		}
+
+		switch {
+		case result.Err != nil:
+			klog.Warningf("Relist %d failed: %v", attempt, result.Err)
+		case relistThreshold > 0 && result.Duration > relistThreshold:
+			klog.Warningf("Relist %d of %d pods took %v, threshold is %v", attempt, len(result.Pods), result.Duration, relistThreshold)
+		default:
+			klog.Infof("Runtime responsive, relist %d of %d pods took %v", attempt, len(result.Pods), result.Duration)
+			return writeReadyFile(result)
+		}
+
+		select {
+		case <-deadline:
+			return fmt.Errorf("runtime not responsive within %v after %d relists", gateTimeout, attempt)
+		case <-rs.Clock.After(gateRetryInterval):
+		}
+	}
+}
+
+func writeReadyFile(result *relistResult) error {
+	if err := os.MkdirAll(filepath.Dir(readyFile), 0755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("time: %s\nrelist: %v\npods: %d\n", formatTime(result.Time), result.Duration, len(result.Pods))
+	return ioutil.WriteFile(readyFile, []byte(content), 0644)
+}
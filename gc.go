@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// olderThan is the minimum age of the containers and sandboxes to collect.
+	olderThan = 24 * time.Hour
+	// prune removes the garbage found by the gc command.
+	prune = false
+	// gcStates restricts the gc command to the comma separated states, exited
+	// for containers and notready for sandboxes.
+	gcStates = ""
+	// gcNamespaces restricts the gc command to the comma separated namespaces.
+	gcNamespaces = ""
+	// pruneFromPlan removes the garbage of a reviewed plan of the gc command.
+	pruneFromPlan = ""
+	// assumeYes skips the confirmation of -prune.
+	assumeYes = false
+	// gcStalledContainers is the number of collectable containers from which
+	// the garbage collection of the kubelet is considered stalled.
+	gcStalledContainers = 100
+)
+
+// gcCandidate is a container or sandbox the kubelet should have collected.
+type gcCandidate struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id"`
+	PodUID    string `json:"podUID"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Container is the name of the container, empty for sandboxes.
+	Container string    `json:"container,omitempty"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"createdAt"`
+	Reason    string    `json:"reason"`
+}
+
+// gcPlan is the exact list of the removals of -prune, which can be reviewed
+// and executed later with -prune-from-plan.
+type gcPlan struct {
+	Time      time.Time      `json:"time"`
+	Node      string         `json:"node"`
+	OlderThan string         `json:"olderThan"`
+	Items     []*gcCandidate `json:"items"`
+}
+
+func writePlan(w io.Writer, plan *gcPlan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(plan)
+}
+
+func readPlan(path string) (*gcPlan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	plan := &gcPlan{}
+	if err := json.NewDecoder(f).Decode(plan); err != nil {
+		return nil, fmt.Errorf("parse plan %s: %v", path, err)
+	}
+	return plan, nil
+}
+
+// checkPlan rejects a plan made on another node, and drops the items of the
+// plan which are no longer garbage since it was made, e.g. restarted
+// containers, removed sandboxes or pods which got a newer sandbox.
+func (rs *runtimeService) checkPlan(plan *gcPlan) ([]*gcCandidate, error) {
+	if plan.Node != nodeName {
+		return nil, fmt.Errorf("the plan was made on node %q, this is node %q", plan.Node, nodeName)
+	}
+	minAge, err := time.ParseDuration(plan.OlderThan)
+	if err != nil {
+		return nil, fmt.Errorf("olderThan of the plan: %v", err)
+	}
+	candidates, err := rs.gcCandidates(rs.Clock.Now(), minAge)
+	if err != nil {
+		return nil, err
+	}
+	garbage := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		garbage[c.Kind+"/"+c.ID] = true
+	}
+
+	var items []*gcCandidate
+	for _, item := range plan.Items {
+		if garbage[item.Kind+"/"+item.ID] {
+			items = append(items, item)
+		} else {
+			klog.Warningf("Skip %s %s of the plan, it is gone or no longer garbage", item.Kind, item.ID)
+		}
+	}
+	return items, nil
+}
+
+// podsWithoutDir returns whether a pod is known to be gone, its directory in
+// dir removed by the kubelet. Nothing is known if dir can not be read.
+func podsWithoutDir(dir string) func(uid string) bool {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		klog.V(2).Infof("Keep the newest sandbox of every pod, read pod directories failed: %v", err)
+		return func(string) bool { return false }
+	}
+	dirs := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		dirs[entry.Name()] = true
+	}
+	return func(uid string) bool { return !dirs[uid] }
+}
+
+// gcCandidates finds the garbage older than minAge the way the kubelet
+// collects it: exited containers beyond the newest one of every pod
+// container, and sandboxes which are not ready and have no containers. The
+// newest sandbox of a pod is only garbage once the pod is gone.
+func (rs *runtimeService) gcCandidates(now time.Time, minAge time.Duration) ([]*gcCandidate, error) {
+	containers, err := rs.getKubeletContainers("", true)
+	if err != nil {
+		return nil, err
+	}
+	sandboxes, err := rs.getKubeletSandboxs("", true)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*gcCandidate
+	exited := make(map[string][]*runtimeapi.Container)
+	sandboxContainers := make(map[string]int)
+	for _, c := range containers {
+		sandboxContainers[c.PodSandboxId]++
+		if c.State != runtimeapi.ContainerState_CONTAINER_EXITED {
+			continue
+		}
+		info := getContainerInfoFromLabels(c.Labels)
+		key := info.PodUID + "/" + c.GetMetadata().GetName()
+		exited[key] = append(exited[key], c)
+	}
+	for _, cs := range exited {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].CreatedAt > cs[j].CreatedAt })
+		for _, c := range cs[1:] {
+			createdAt := time.Unix(0, c.CreatedAt)
+			if now.Sub(createdAt) < minAge {
+				continue
+			}
+			info := getContainerInfoFromLabels(c.Labels)
+			candidates = append(candidates, &gcCandidate{
+				Kind:      "container",
+				ID:        c.Id,
+				PodUID:    info.PodUID,
+				Namespace: info.PodNamespace,
+				Name:      info.PodName,
+				Container: c.GetMetadata().GetName(),
+				State:     "exited",
+				CreatedAt: createdAt,
+				Reason:    fmt.Sprintf("exited, one of %d exited containers of the same pod container", len(cs)),
+			})
+		}
+	}
+
+	gone := podsWithoutDir(kubeletPodsDir)
+	newest := make(map[string]*runtimeapi.PodSandbox)
+	for _, s := range sandboxes {
+		uid := s.GetMetadata().GetUid()
+		if n, ok := newest[uid]; !ok || s.CreatedAt > n.CreatedAt {
+			newest[uid] = s
+		}
+	}
+	for _, s := range sandboxes {
+		createdAt := time.Unix(0, s.CreatedAt)
+		if s.State == runtimeapi.PodSandboxState_SANDBOX_READY || sandboxContainers[s.Id] > 0 || now.Sub(createdAt) < minAge {
+			continue
+		}
+		reason := "not ready without containers"
+		if uid := s.GetMetadata().GetUid(); newest[uid] == s {
+			// The kubelet keeps the newest sandbox of pods which still exist.
+			if !gone(uid) {
+				continue
+			}
+			reason = "not ready without containers, newest sandbox of a pod without directory in " + kubeletPodsDir
+		}
+		candidates = append(candidates, &gcCandidate{
+			Kind:      "sandbox",
+			ID:        s.Id,
+			PodUID:    s.GetMetadata().GetUid(),
+			Namespace: s.GetMetadata().GetNamespace(),
+			Name:      s.GetMetadata().GetName(),
+			State:     "notready",
+			CreatedAt: createdAt,
+			Reason:    reason,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].CreatedAt.Before(candidates[j].CreatedAt) })
+	return candidates, nil
+}
+
+// splitList splits a comma separated flag, an empty flag is an empty list.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCandidates keeps the candidates in one of states and namespaces,
+// empty lists match everything.
+func filterCandidates(candidates []*gcCandidate, states, namespaces []string) []*gcCandidate {
+	var filtered []*gcCandidate
+	for _, c := range candidates {
+		if len(states) > 0 && !contains(states, c.State) {
+			continue
+		}
+		if len(namespaces) > 0 && !contains(namespaces, c.Namespace) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+// gcAdvise prints recommendations about the garbage.
+func gcAdvise(candidates []*gcCandidate) {
+	containers, sandboxes := 0, 0
+	for _, c := range candidates {
+		if c.Kind == "container" {
+			containers++
+		} else {
+			sandboxes++
+		}
+	}
+	switch {
+	case containers >= gcStalledContainers:
+		fmt.Printf("%d exited containers older than %v; kubelet GC appears stalled\n", containers, olderThan)
+	case containers > 0:
+		fmt.Printf("%d exited containers older than %v can be removed\n", containers, olderThan)
+	}
+	if sandboxes > 0 {
+		fmt.Printf("%d dead sandboxes older than %v can be removed\n", sandboxes, olderThan)
+	}
+	if containers == 0 && sandboxes == 0 {
+		fmt.Printf("No exited containers or dead sandboxes older than %v\n", olderThan)
+	}
+}
+
+// confirm asks the user on the terminal, unless -yes is set.
+func confirm(question string) bool {
+	if assumeYes {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// remove removes the garbage, containers first so their sandboxes are empty,
+// and prints the latency of every removal since slow removals are a symptom
+// of the runtime too.
+func (rs *runtimeService) remove(candidates []*gcCandidate) error {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tID\tPOD\tLATENCY\tRESULT")
+	for _, kind := range []string{"container", "sandbox"} {
+		for _, c := range candidates {
+			if c.Kind != kind {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+			now := rs.Clock.Now()
+			var err error
+			if kind == "container" {
+				_, err = rs.Client.RemoveContainer(ctx, &runtimeapi.RemoveContainerRequest{ContainerId: c.ID})
+			} else {
+				_, err = rs.Client.RemovePodSandbox(ctx, &runtimeapi.RemovePodSandboxRequest{PodSandboxId: c.ID})
+			}
+			elapsed := rs.Clock.Since(now)
+			cancel()
+			result := "removed"
+			if err != nil {
+				failed++
+				result = err.Error()
+				klog.Errorf("Remove %s %s failed: %v", kind, c.ID, err)
+			} else {
+				klog.V(2).Infof("Removed %s %s of pod %s/%s, Threshold: %v", kind, c.ID, c.Namespace, c.Name, elapsed)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%s\n", kind, c.ID, c.Namespace, c.Name, formatDuration(elapsed), result)
+		}
+	}
+	w.Flush()
+	if failed > 0 {
+		return fmt.Errorf("%d removals failed", failed)
+	}
+	return nil
+}
+
+// gc advises about the garbage the kubelet did not collect, and removes it
+// with -prune, after printing the plan of the removals.
+func gc(rs *runtimeService) error {
+	if pruneFromPlan != "" {
+		plan, err := readPlan(pruneFromPlan)
+		if err != nil {
+			return err
+		}
+		candidates, err := rs.checkPlan(plan)
+		if err != nil {
+			return err
+		}
+		return rs.confirmRemove(candidates)
+	}
+
+	now := rs.Clock.Now()
+	candidates, err := rs.gcCandidates(now, olderThan)
+	if err != nil {
+		return err
+	}
+	candidates = filterCandidates(candidates, splitList(gcStates), splitList(gcNamespaces))
+	if output == "json" || prune {
+		if err := writePlan(os.Stdout, &gcPlan{Time: now, Node: nodeName, OlderThan: olderThan.String(), Items: candidates}); err != nil {
+			return err
+		}
+	} else {
+		gcAdvise(candidates)
+	}
+	if !prune {
+		return nil
+	}
+	return rs.confirmRemove(candidates)
+}
+
+func (rs *runtimeService) confirmRemove(candidates []*gcCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if readOnly {
+		return fmt.Errorf("removing containers and sandboxes modifies the node, set -read-only=false to prune")
+	}
+	if !confirm(fmt.Sprintf("Remove %d containers and sandboxes?", len(candidates))) {
+		return fmt.Errorf("prune not confirmed")
+	}
+	return rs.remove(candidates)
+}
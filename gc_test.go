@@ -0,0 +1,365 @@
+package main
+
+import (
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gcPodLabels are the labels the kubelet sets on the sandboxes and
+// containers of a pod.
+func gcPodLabels(uid, namespace string) map[string]string {
+	return map[string]string{
+		KubernetesPodUIDLabel:       uid,
+		KubernetesPodNameLabel:      "pod-" + uid,
+		KubernetesPodNamespaceLabel: namespace,
+	}
+}
+
+func gcSandbox(id, uid, namespace string, state runtimeapi.PodSandboxState, createdAt time.Time) *runtimeapi.PodSandbox {
+	return &runtimeapi.PodSandbox{
+		Id:        id,
+		Metadata:  &runtimeapi.PodSandboxMetadata{Name: "pod-" + uid, Namespace: namespace, Uid: uid},
+		State:     state,
+		CreatedAt: createdAt.UnixNano(),
+		Labels:    gcPodLabels(uid, namespace),
+	}
+}
+
+func gcContainer(id, sandbox, uid, namespace string, state runtimeapi.ContainerState, createdAt time.Time) *runtimeapi.Container {
+	labels := gcPodLabels(uid, namespace)
+	labels[KubernetesContainerNameLabel] = "app"
+	return &runtimeapi.Container{
+		Id:           id,
+		PodSandboxId: sandbox,
+		Metadata:     &runtimeapi.ContainerMetadata{Name: "app"},
+		State:        state,
+		CreatedAt:    createdAt.UnixNano(),
+		Labels:       labels,
+	}
+}
+
+// newGCRuntimeService returns a runtime service of a node with garbage:
+//
+//   - pod a in default has an old dead sandbox, and two old exited containers
+//     besides the newest exited one in its ready sandbox,
+//   - pod b in kube-system is gone, its only sandbox is dead,
+//   - pod c in default still exists, its newest sandbox is dead,
+//   - pod d in default is gone, its dead sandbox is an hour old.
+//
+// The directories of the pods which still exist are created in a temporary
+// kubeletPodsDir, the returned function restores it.
+func newGCRuntimeService(t *testing.T) (*runtimeService, *fakeRuntimeClient, func()) {
+	dir, err := ioutil.TempDir("", "oncepleg-gc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, uid := range []string{"uid-a", "uid-c"} {
+		if err := os.Mkdir(filepath.Join(dir, uid), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	savedDir, savedNode := kubeletPodsDir, nodeName
+	kubeletPodsDir, nodeName = dir, "node-1"
+	restore := func() {
+		kubeletPodsDir, nodeName = savedDir, savedNode
+		os.RemoveAll(dir)
+	}
+
+	clock := newFakeClock()
+	now := clock.Now()
+	ready, notReady := runtimeapi.PodSandboxState_SANDBOX_READY, runtimeapi.PodSandboxState_SANDBOX_NOTREADY
+	exited := runtimeapi.ContainerState_CONTAINER_EXITED
+	f := newFakeRuntimeClient(0, 0, now)
+	f.sandboxes = []*runtimeapi.PodSandbox{
+		gcSandbox("sb-a-old", "uid-a", "default", notReady, now.Add(-72*time.Hour)),
+		gcSandbox("sb-a", "uid-a", "default", ready, now.Add(-48*time.Hour)),
+		gcSandbox("sb-b", "uid-b", "kube-system", notReady, now.Add(-48*time.Hour)),
+		gcSandbox("sb-c", "uid-c", "default", notReady, now.Add(-48*time.Hour)),
+		gcSandbox("sb-d", "uid-d", "default", notReady, now.Add(-time.Hour)),
+	}
+	f.containers = []*runtimeapi.Container{
+		gcContainer("c-a-1", "sb-a", "uid-a", "default", exited, now.Add(-48*time.Hour)),
+		gcContainer("c-a-2", "sb-a", "uid-a", "default", exited, now.Add(-30*time.Hour)),
+		gcContainer("c-a-3", "sb-a", "uid-a", "default", exited, now.Add(-time.Hour)),
+		gcContainer("c-a-4", "sb-a", "uid-a", "default", runtimeapi.ContainerState_CONTAINER_RUNNING, now.Add(-time.Hour)),
+	}
+	rs := &runtimeService{
+		Client:  f,
+		Timeout: time.Minute,
+		Stats:   newRPCStats(clock),
+		Clock:   clock,
+	}
+	return rs, f, restore
+}
+
+func candidateIDs(candidates []*gcCandidate) []string {
+	ids := []string{}
+	for _, c := range candidates {
+		ids = append(ids, c.Kind+"/"+c.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestGCCandidates(t *testing.T) {
+	rs, _, restore := newGCRuntimeService(t)
+	defer restore()
+	tests := []struct {
+		name   string
+		minAge time.Duration
+		want   []string
+	}{
+		{
+			name:   "everything",
+			minAge: 0,
+			want:   []string{"container/c-a-1", "container/c-a-2", "sandbox/sb-a-old", "sandbox/sb-b", "sandbox/sb-d"},
+		},
+		{
+			name:   "a day old",
+			minAge: 24 * time.Hour,
+			want:   []string{"container/c-a-1", "container/c-a-2", "sandbox/sb-a-old", "sandbox/sb-b"},
+		},
+		{
+			name:   "cutoff between the exited containers",
+			minAge: 36 * time.Hour,
+			want:   []string{"container/c-a-1", "sandbox/sb-a-old", "sandbox/sb-b"},
+		},
+		{
+			name:   "older than everything",
+			minAge: 100 * time.Hour,
+			want:   []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			candidates, err := rs.gcCandidates(rs.Clock.Now(), test.minAge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := candidateIDs(candidates); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("candidates %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterCandidates(t *testing.T) {
+	rs, _, restore := newGCRuntimeService(t)
+	defer restore()
+	candidates, err := rs.gcCandidates(rs.Clock.Now(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		states     string
+		namespaces string
+		want       []string
+	}{
+		{
+			name: "no filter",
+			want: []string{"container/c-a-1", "container/c-a-2", "sandbox/sb-a-old", "sandbox/sb-b"},
+		},
+		{
+			name:   "exited",
+			states: "exited",
+			want:   []string{"container/c-a-1", "container/c-a-2"},
+		},
+		{
+			name:   "both states",
+			states: "exited,notready",
+			want:   []string{"container/c-a-1", "container/c-a-2", "sandbox/sb-a-old", "sandbox/sb-b"},
+		},
+		{
+			name:       "namespace",
+			namespaces: "kube-system",
+			want:       []string{"sandbox/sb-b"},
+		},
+		{
+			name:       "state and namespace",
+			states:     "notready",
+			namespaces: "default",
+			want:       []string{"sandbox/sb-a-old"},
+		},
+		{
+			name:       "unknown namespace",
+			namespaces: "monitoring",
+			want:       []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filtered := filterCandidates(candidates, splitList(test.states), splitList(test.namespaces))
+			if got := candidateIDs(filtered); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("candidates %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckPlan(t *testing.T) {
+	rs, f, restore := newGCRuntimeService(t)
+	defer restore()
+	items := func(ids ...string) []*gcCandidate {
+		var candidates []*gcCandidate
+		for _, id := range ids {
+			kind := strings.SplitN(id, "/", 2)
+			candidates = append(candidates, &gcCandidate{Kind: kind[0], ID: kind[1]})
+		}
+		return candidates
+	}
+	tests := []struct {
+		name string
+		plan *gcPlan
+		// change is applied to the node after the plan is made.
+		change func()
+		want   []string
+		err    string
+	}{
+		{
+			name: "unchanged",
+			plan: &gcPlan{Node: "node-1", OlderThan: "24h0m0s", Items: items("container/c-a-1", "sandbox/sb-b")},
+			want: []string{"container/c-a-1", "sandbox/sb-b"},
+		},
+		{
+			name: "other node",
+			plan: &gcPlan{Node: "node-2", OlderThan: "24h0m0s", Items: items("container/c-a-1")},
+			err:  `the plan was made on node "node-2", this is node "node-1"`,
+		},
+		{
+			name: "bad age",
+			plan: &gcPlan{Node: "node-1", OlderThan: "a day", Items: items("container/c-a-1")},
+			err:  "olderThan of the plan",
+		},
+		{
+			name: "younger than the age of the plan",
+			plan: &gcPlan{Node: "node-1", OlderThan: "36h0m0s", Items: items("container/c-a-1", "container/c-a-2")},
+			want: []string{"container/c-a-1"},
+		},
+		{
+			name: "sandbox got a container",
+			plan: &gcPlan{Node: "node-1", OlderThan: "24h0m0s", Items: items("sandbox/sb-a-old", "sandbox/sb-b")},
+			change: func() {
+				f.containers = append(f.containers, gcContainer("c-b-1", "sb-b", "uid-b", "kube-system", runtimeapi.ContainerState_CONTAINER_CREATED, rs.Clock.Now()))
+			},
+			want: []string{"sandbox/sb-a-old"},
+		},
+		{
+			name: "container collected by the kubelet",
+			plan: &gcPlan{Node: "node-1", OlderThan: "24h0m0s", Items: items("container/c-a-1", "container/c-a-2")},
+			change: func() {
+				f.containers = f.containers[1:]
+			},
+			want: []string{"container/c-a-2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saved := f.containers
+			defer func() { f.containers = saved }()
+			if test.change != nil {
+				test.change()
+			}
+			candidates, err := rs.checkPlan(test.plan)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := candidateIDs(candidates); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("candidates %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfirmRemoveReadOnly(t *testing.T) {
+	rs, f, restore := newGCRuntimeService(t)
+	defer restore()
+	defer func(r, y bool) { readOnly, assumeYes = r, y }(readOnly, assumeYes)
+	candidates, err := rs.gcCandidates(rs.Clock.Now(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readOnly, assumeYes = true, true
+	if err := rs.confirmRemove(nil); err != nil {
+		t.Errorf("nothing to remove in read-only mode: %v", err)
+	}
+	if err := rs.confirmRemove(candidates); err == nil || !strings.Contains(err.Error(), "-read-only=false") {
+		t.Errorf("error %v, want the read-only refusal", err)
+	}
+	if len(f.removed) != 0 {
+		t.Fatalf("removed %v in read-only mode", f.removed)
+	}
+
+	readOnly = false
+	if err := rs.confirmRemove(candidates); err != nil {
+		t.Fatal(err)
+	}
+	// The containers are removed first, so their sandboxes are empty.
+	want := []string{"container/c-a-1", "container/c-a-2", "sandbox/sb-a-old", "sandbox/sb-b"}
+	if !reflect.DeepEqual(f.removed, want) {
+		t.Errorf("removed %v, want %v", f.removed, want)
+	}
+}
+
+// TestPlanRoundTrip checks a plan written by gc and applied later with
+// -prune-from-plan removes only what is still garbage.
+func TestPlanRoundTrip(t *testing.T) {
+	rs, f, restore := newGCRuntimeService(t)
+	defer restore()
+	defer func(r, y bool) { readOnly, assumeYes = r, y }(readOnly, assumeYes)
+	readOnly, assumeYes = false, true
+
+	candidates, err := rs.gcCandidates(rs.Clock.Now(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ioutil.TempFile("", "oncepleg-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	err = writePlan(file, &gcPlan{Time: rs.Clock.Now(), Node: nodeName, OlderThan: (24 * time.Hour).String(), Items: candidates})
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Between the plan and its review, the exited container c-a-2 becomes
+	// the newest one of its pod container, its successor being collected by
+	// the kubelet, and the sandbox of pod b gets a container.
+	f.containers = append([]*runtimeapi.Container{f.containers[0], f.containers[1]}, gcContainer("c-b-1", "sb-b", "uid-b", "kube-system", runtimeapi.ContainerState_CONTAINER_CREATED, rs.Clock.Now()))
+	rs.Clock.(*fakeClock).Step(time.Hour)
+
+	plan, err := readPlan(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := candidateIDs(plan.Items); !reflect.DeepEqual(got, candidateIDs(candidates)) {
+		t.Fatalf("plan read back with %v, want %v", got, candidateIDs(candidates))
+	}
+	items, err := rs.checkPlan(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.confirmRemove(items); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"container/c-a-1", "sandbox/sb-a-old"}
+	if !reflect.DeepEqual(f.removed, want) {
+		t.Errorf("removed %v, want %v", f.removed, want)
+	}
+}
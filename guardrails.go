@@ -0,0 +1,93 @@
+package main
+
+import "k8s.io/klog"
+
+var (
+	// maxRSS and maxCPU are soft limits of the resources of the watch, in
+	// bytes and percent of one cpu. Zero disables them.
+	maxRSS int64
+	maxCPU float64
+	// maxIntervalFactor bounds how much the watch slows down over budget.
+	maxIntervalFactor = 8
+	// The work shed over budget is restored one step at a time once the
+	// watch stays under budgetRecovery of its budget for budgetRecoveryRelists
+	// relists, so it does not flap around the limit.
+	budgetRecovery        = 0.8
+	budgetRecoveryRelists = 3
+)
+
+var (
+	// shedProcesses, shedCgroups and shedNamespaces are the verbose checks
+	// disabled over budget, to be enabled again under it.
+	shedProcesses, shedCgroups, shedNamespaces bool
+	// underBudget counts the relists in a row under budgetRecovery.
+	underBudget int
+)
+
+// budgetUsage returns the resource of the watch closest to its limit and the
+// fraction of the limit it uses, over 1 is over budget.
+func budgetUsage(self *selfStats) (string, float64) {
+	resource, usage := "", 0.0
+	if maxRSS > 0 && self.RSS >= 0 {
+		resource, usage = "rss", float64(self.RSS)/float64(maxRSS)
+	}
+	if maxCPU > 0 && self.CPU >= 0 && self.CPU/maxCPU > usage {
+		resource, usage = "cpu", self.CPU/maxCPU
+	}
+	return resource, usage
+}
+
+// adaptToBudget reduces the work of the watch when oncepleg itself uses more
+// than its budget: the verbose statuses and namespace inspection are disabled
+// first, then the interval is doubled up to maxIntervalFactor times. Back
+// under budget, the steps are undone in the reverse order.
+func adaptToBudget(self *selfStats) {
+	if self == nil {
+		return
+	}
+	resource, usage := budgetUsage(self)
+	switch {
+	case usage > 1:
+		underBudget = 0
+		shedWork(resource, self)
+	case usage < budgetRecovery:
+		underBudget++
+		if underBudget >= budgetRecoveryRelists {
+			underBudget = 0
+			restoreWork(self)
+		}
+	default:
+		underBudget = 0
+	}
+}
+
+func shedWork(over string, self *selfStats) {
+	if inspectProcesses || inspectCgroups || inspectNamespaces {
+		shedProcesses, shedCgroups, shedNamespaces = shedProcesses || inspectProcesses, shedCgroups || inspectCgroups, shedNamespaces || inspectNamespaces
+		inspectProcesses, inspectCgroups, inspectNamespaces = false, false, false
+		klog.Warningf("Over %s budget (%s), disable verbose statuses", over, self)
+		return
+	}
+	if intervalFactor < maxIntervalFactor {
+		intervalFactor *= 2
+		klog.Warningf("Over %s budget (%s), relist %d times less often", over, self, intervalFactor)
+	}
+}
+
+// restoreWork undoes the last step of shedWork.
+func restoreWork(self *selfStats) {
+	if intervalFactor > 1 {
+		intervalFactor /= 2
+		if intervalFactor == 1 {
+			klog.Infof("Back under budget (%s), relist at the configured interval", self)
+		} else {
+			klog.Infof("Back under budget (%s), relist %d times less often", self, intervalFactor)
+		}
+		return
+	}
+	if shedProcesses || shedCgroups || shedNamespaces {
+		inspectProcesses, inspectCgroups, inspectNamespaces = shedProcesses, shedCgroups, shedNamespaces
+		shedProcesses, shedCgroups, shedNamespaces = false, false, false
+		klog.Infof("Back under budget (%s), enable verbose statuses", self)
+	}
+}
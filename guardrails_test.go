@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestBudgetShedsAndRecovers(t *testing.T) {
+	savedRSS, savedFactor := maxRSS, intervalFactor
+	savedChecks := []bool{inspectProcesses, inspectCgroups, inspectNamespaces}
+	defer func() {
+		maxRSS, intervalFactor = savedRSS, savedFactor
+		inspectProcesses, inspectCgroups, inspectNamespaces = savedChecks[0], savedChecks[1], savedChecks[2]
+		shedProcesses, shedCgroups, shedNamespaces, underBudget = false, false, false, 0
+	}()
+	maxRSS, intervalFactor = 100, 1
+	inspectProcesses, inspectCgroups, inspectNamespaces = true, false, true
+	over, near, under := &selfStats{RSS: 150, CPU: -1}, &selfStats{RSS: 90, CPU: -1}, &selfStats{RSS: 50, CPU: -1}
+
+	adaptToBudget(over)
+	if inspectProcesses || inspectNamespaces || intervalFactor != 1 {
+		t.Fatalf("over budget: processes %v, namespaces %v, interval factor %d, want the verbose checks shed first", inspectProcesses, inspectNamespaces, intervalFactor)
+	}
+	adaptToBudget(over)
+	if intervalFactor != 2 {
+		t.Fatalf("interval factor %d, want 2", intervalFactor)
+	}
+
+	// Close to the limit, nothing is restored.
+	for i := 0; i < 2*budgetRecoveryRelists; i++ {
+		adaptToBudget(near)
+	}
+	if intervalFactor != 2 {
+		t.Fatalf("interval factor %d near the limit, want 2", intervalFactor)
+	}
+
+	// A relist near the limit restarts the count.
+	adaptToBudget(under)
+	adaptToBudget(near)
+	for i := 0; i < budgetRecoveryRelists-1; i++ {
+		adaptToBudget(under)
+	}
+	if intervalFactor != 2 {
+		t.Fatalf("interval factor %d, restored before %d relists under budget", intervalFactor, budgetRecoveryRelists)
+	}
+	adaptToBudget(under)
+	if intervalFactor != 1 || inspectProcesses {
+		t.Fatalf("interval factor %d, processes %v, want the interval restored first", intervalFactor, inspectProcesses)
+	}
+	for i := 0; i < budgetRecoveryRelists; i++ {
+		adaptToBudget(under)
+	}
+	if !inspectProcesses || inspectCgroups || !inspectNamespaces {
+		t.Errorf("processes %v, cgroups %v, namespaces %v, want the checks enabled before", inspectProcesses, inspectCgroups, inspectNamespaces)
+	}
+}
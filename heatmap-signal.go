@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"k8s.io/klog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleHeatmapSignal writes the latency heatmaps to stderr on SIGUSR2.
+func handleHeatmapSignal(stats *rpcStats) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	go func() {
+		for range signals {
+			if err := writeHeatmaps(os.Stderr, stats.heatmaps()); err != nil {
+				klog.Errorf("Write heatmaps failed: %v", err)
+			}
+		}
+	}()
+}
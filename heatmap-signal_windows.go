@@ -0,0 +1,4 @@
+package main
+
+// handleHeatmapSignal does nothing, there is no SIGUSR2 on Windows.
+func handleHeatmapSignal(stats *rpcStats) {}
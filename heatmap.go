@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// heatmapSamples is the number of recent latencies kept per CRI method for
+	// the heatmaps, a latency takes 32 bytes.
+	heatmapSamples = 1000
+	// heatmapColumns is the number of time buckets of a heatmap.
+	heatmapColumns = 30
+)
+
+// heatmapBounds are the upper bounds of the latency buckets of a heatmap,
+// the last bucket holds everything slower.
+var heatmapBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// heatmapShades render the number of calls of a cell, from none to the most.
+const heatmapShades = " .:-=+*#%@"
+
+type latencySample struct {
+	Time    time.Time
+	Latency time.Duration
+}
+
+// latencyRing keeps the most recent heatmapSamples latencies.
+type latencyRing struct {
+	samples []latencySample
+	next    int
+}
+
+func (r *latencyRing) add(s latencySample) {
+	if len(r.samples) < heatmapSamples {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+// all returns the samples from the oldest to the most recent.
+func (r *latencyRing) all() []latencySample {
+	samples := make([]latencySample, 0, len(r.samples))
+	samples = append(samples, r.samples[r.next:]...)
+	return append(samples, r.samples[:r.next]...)
+}
+
+// heatmap counts the calls of a method by time bucket and latency bucket, to
+// tell whether slowness is constant, periodic or bursty.
+type heatmap struct {
+	Method string    `json:"method"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	// Bounds are the upper bounds of the latency buckets in nanoseconds, the
+	// last row of Counts holds the slower calls.
+	Bounds []int64 `json:"boundsNs"`
+	// Counts are indexed by latency bucket then time bucket.
+	Counts [][]int `json:"counts"`
+}
+
+func newHeatmap(method string, samples []latencySample) *heatmap {
+	h := &heatmap{Method: method, Counts: make([][]int, len(heatmapBounds)+1)}
+	for _, b := range heatmapBounds {
+		h.Bounds = append(h.Bounds, int64(b))
+	}
+	for i := range h.Counts {
+		h.Counts[i] = make([]int, heatmapColumns)
+	}
+	if len(samples) == 0 {
+		return h
+	}
+	h.Start, h.End = samples[0].Time, samples[len(samples)-1].Time
+	span := h.End.Sub(h.Start)
+	for _, s := range samples {
+		column := 0
+		if span > 0 {
+			column = int(int64(s.Time.Sub(h.Start)) * int64(heatmapColumns-1) / int64(span))
+		}
+		row := sort.Search(len(heatmapBounds), func(i int) bool { return s.Latency <= heatmapBounds[i] })
+		h.Counts[row][column]++
+	}
+	return h
+}
+
+// render draws the heatmap with the slowest bucket on top.
+func (h *heatmap) render(out io.Writer) {
+	max := 0
+	for _, row := range h.Counts {
+		for _, n := range row {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	fmt.Fprintf(out, "%s %s - %s\n", h.Method, h.Start.Format("15:04:05"), h.End.Format("15:04:05"))
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		label := ">" + heatmapBounds[len(heatmapBounds)-1].String()
+		if i < len(heatmapBounds) {
+			label = "<=" + heatmapBounds[i].String()
+		}
+		var b strings.Builder
+		for _, n := range h.Counts[i] {
+			shade := 0
+			if n > 0 {
+				shade = 1 + (n-1)*(len(heatmapShades)-2)/max
+			}
+			b.WriteByte(heatmapShades[shade])
+		}
+		fmt.Fprintf(out, "%8s |%s|\n", label, b.String())
+	}
+}
+
+// writeHeatmaps writes the heatmaps of every method in the selected output format.
+func writeHeatmaps(out io.Writer, heatmaps []*heatmap) error {
+	if output == "json" {
+		return json.NewEncoder(out).Encode(heatmaps)
+	}
+	for _, h := range heatmaps {
+		h.render(out)
+	}
+	return nil
+}
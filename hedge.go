@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hedgeAfter is the delay after which a status call still pending gets a
+// second attempt, zero disables hedging.
+var hedgeAfter = time.Duration(0)
+
+// hedgeMethods are the methods which may be hedged, all of them read-only.
+var hedgeMethods = map[string]bool{
+	"ContainerStatus":  true,
+	"PodSandboxStatus": true,
+}
+
+// hedgeCounts tells whether hedging helps: if the second attempt often
+// answers first the latency is stochastic, if it rarely does the runtime is
+// slow for every caller and the latency is structural.
+type hedgeCounts struct {
+	// Hedged counts the calls which got a second attempt, Won those the
+	// second attempt answered first.
+	Hedged int `json:"hedged"`
+	Won    int `json:"won"`
+}
+
+var (
+	hedgesMu sync.Mutex
+	hedges   hedgeCounts
+)
+
+// resetHedges returns the hedge counts since the previous reset, nil if no
+// call was hedged.
+func resetHedges() *hedgeCounts {
+	hedgesMu.Lock()
+	defer hedgesMu.Unlock()
+	counts := hedges
+	hedges = hedgeCounts{}
+	if counts.Hedged == 0 {
+		return nil
+	}
+	return &counts
+}
+
+// hedgedKey is the context key of the flag hedgeCall sets when it issues a
+// second attempt, for the stats to time the hedged calls apart.
+type hedgedKey struct{}
+
+// hedgeCall returns a grpc.UnaryClientInterceptor issuing a second attempt of a
+// status call pending for hedgeAfter, and using whichever answers first. It
+// is chained after the stats interceptor, which sees one logical call timed
+// apart from the calls answered by their first attempt: the attempts are only
+// counted in the hedge counts, the cancelled loser is no error. c times the
+// hedge delay.
+func hedgeCall(c clock) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		name := method[strings.LastIndexByte(method, '/')+1:]
+		if hedgeAfter <= 0 || !hedgeMethods[name] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		// The loser is cancelled once the winner answers.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type attempt struct {
+			reply interface{}
+			err   error
+			hedge bool
+		}
+		done := make(chan attempt, 2)
+		call := func(hedge bool) {
+			// Each attempt decodes into its own reply, the winner is copied.
+			r := reflect.New(reflect.TypeOf(reply).Elem()).Interface()
+			done <- attempt{r, invoker(ctx, method, req, r, cc, opts...), hedge}
+		}
+		finish := func(a attempt) error {
+			if a.err == nil {
+				reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(a.reply).Elem())
+			}
+			return a.err
+		}
+
+		go call(false)
+		timer := c.NewTimer(hedgeAfter)
+		defer timer.Stop()
+		select {
+		case a := <-done:
+			return finish(a)
+		case <-timer.C():
+		}
+
+		if flag, ok := ctx.Value(hedgedKey{}).(*int32); ok {
+			atomic.StoreInt32(flag, 1)
+		}
+		go call(true)
+		a := <-done
+		if a.err != nil {
+			// The other attempt may still succeed.
+			if b := <-done; b.err == nil {
+				a = b
+			}
+		}
+		hedgesMu.Lock()
+		hedges.Hedged++
+		if a.hedge && a.err == nil {
+			hedges.Won++
+		}
+		hedgesMu.Unlock()
+		return finish(a)
+	}
+}
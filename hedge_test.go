@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"google.golang.org/grpc"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHedgeAfter checks the second attempt is sent once the clock reaches
+// hedgeAfter and its answer is used when the first one hangs, and the hedged
+// call is timed apart from the calls answered by their first attempt.
+func TestHedgeAfter(t *testing.T) {
+	defer func(d time.Duration) { hedgeAfter = d }(hedgeAfter)
+	hedgeAfter = time.Second
+	resetHedges()
+	clock := newFakeClock()
+	attempts := make(chan int, 2)
+	var sent int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts <- 1
+		if atomic.AddInt32(&sent, 1) == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		reply.(*runtimeapi.ContainerStatusResponse).Status = &runtimeapi.ContainerStatus{Id: "hedged"}
+		return nil
+	}
+	stats := newRPCStats(clock)
+	hedged := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return hedgeCall(clock)(ctx, method, req, reply, cc, invoker, opts...)
+	}
+	call := func(reply *runtimeapi.ContainerStatusResponse) error {
+		return stats.intercept(context.Background(), "/runtime.v1alpha2.RuntimeService/ContainerStatus", &runtimeapi.ContainerStatusRequest{}, reply, nil, hedged)
+	}
+	reply := &runtimeapi.ContainerStatusResponse{}
+	done := make(chan error, 1)
+	go func() {
+		done <- call(reply)
+	}()
+	<-attempts
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-attempts:
+		t.Fatal("second attempt sent before hedgeAfter")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Step(hedgeAfter)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if reply.Status.GetId() != "hedged" {
+		t.Errorf("reply of %q, want the second attempt's", reply.Status.GetId())
+	}
+	if counts := resetHedges(); counts == nil || counts.Hedged != 1 || counts.Won != 1 {
+		t.Errorf("hedge counts %+v, want 1 hedged and won", counts)
+	}
+
+	// The third attempt answers right away.
+	if err := call(&runtimeapi.ContainerStatusResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	<-attempts
+	m := stats.reset()["ContainerStatus"]
+	if m == nil || m.Calls != 1 || m.Max != 0 {
+		t.Fatalf("unhedged stats %+v, want 1 call answered at once", m)
+	}
+	if m.Hedged == nil || m.Hedged.Calls != 1 || m.Hedged.Max != hedgeAfter {
+		t.Errorf("hedged stats %+v, want 1 call of %v", m.Hedged, hedgeAfter)
+	}
+	if m.AllCalls() != 2 || m.Slowest() != hedgeAfter {
+		t.Errorf("%d calls, slowest %v, want 2 calls, slowest %v", m.AllCalls(), m.Slowest(), hedgeAfter)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// hostSnapshot is the pressure of the node at the time of a relist. Values that
+// can not be read on this host are set to -1.
+type hostSnapshot struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+	// IOWait is the percentage of cpu time spent in iowait during the relist.
+	IOWait float64 `json:"iowait"`
+	// MemorySome and MemoryFull are the avg10 values of the memory PSI.
+	MemorySome     float64 `json:"memorySome"`
+	MemoryFull     float64 `json:"memoryFull"`
+	ConntrackCount int     `json:"conntrackCount"`
+	ConntrackMax   int     `json:"conntrackMax"`
+}
+
+// cpuTimes are the aggregated jiffies of the "cpu" line in /proc/stat.
+type cpuTimes struct {
+	Total  uint64
+	IOWait uint64
+}
+
+func readCPUTimes() *cpuTimes {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[0] != "cpu" {
+			continue
+		}
+		t := &cpuTimes{}
+		for i, f := range fields[1:] {
+			v, _ := strconv.ParseUint(f, 10, 64)
+			t.Total += v
+			// user nice system idle iowait ...
+			if i == 4 {
+				t.IOWait = v
+			}
+		}
+		return t
+	}
+	return nil
+}
+
+// takeHostSnapshot reads the node pressure, iowait is computed since the given cpu times.
+func takeHostSnapshot(since *cpuTimes) *hostSnapshot {
+	h := &hostSnapshot{
+		Load1: -1, Load5: -1, Load15: -1,
+		IOWait:     -1,
+		MemorySome: -1, MemoryFull: -1,
+		ConntrackCount: readIntFile(filepath.Join(procRoot, "sys/net/netfilter/nf_conntrack_count")),
+		ConntrackMax:   readIntFile(filepath.Join(procRoot, "sys/net/netfilter/nf_conntrack_max")),
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(procRoot, "loadavg")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) >= 3 {
+			h.Load1, _ = strconv.ParseFloat(fields[0], 64)
+			h.Load5, _ = strconv.ParseFloat(fields[1], 64)
+			h.Load15, _ = strconv.ParseFloat(fields[2], 64)
+		}
+	}
+
+	if now := readCPUTimes(); since != nil && now != nil && now.Total > since.Total {
+		h.IOWait = float64(now.IOWait-since.IOWait) * 100 / float64(now.Total-since.Total)
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(procRoot, "pressure/memory")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.HasPrefix(fields[1], "avg10=") {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "avg10="), 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "some":
+				h.MemorySome = v
+			case "full":
+				h.MemoryFull = v
+			}
+		}
+	}
+
+	return h
+}
+
+func (h *hostSnapshot) String() string {
+	conntrack := "n/a"
+	if h.ConntrackCount >= 0 && h.ConntrackMax > 0 {
+		conntrack = fmt.Sprintf("%d/%d (%.1f%%)", h.ConntrackCount, h.ConntrackMax, float64(h.ConntrackCount)*100/float64(h.ConntrackMax))
+	}
+	return fmt.Sprintf("load average: %s %s %s, iowait: %s, memory pressure: some %s full %s, conntrack: %s",
+		formatGauge(h.Load1, "%.2f"), formatGauge(h.Load5, "%.2f"), formatGauge(h.Load15, "%.2f"),
+		formatGauge(h.IOWait, "%.1f%%"), formatGauge(h.MemorySome, "%.2f"), formatGauge(h.MemoryFull, "%.2f"), conntrack)
+}
+
+func formatGauge(v float64, format string) string {
+	if v < 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf(format, v)
+}
+
+func readIntFile(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1
+	}
+	return v
+}
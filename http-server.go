@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"k8s.io/klog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// listenAddress serves /healthz, /heatmap and /events in watch mode, e.g. ":9090".
+	listenAddress = ""
+	// relistHardThreshold is the relist duration from which the watch is unhealthy,
+	// relists over relistThreshold only make it degraded.
+	relistHardThreshold = 10 * time.Minute
+)
+
+const (
+	healthStarting  = "starting"
+	healthHealthy   = "healthy"
+	healthDegraded  = "degraded"
+	healthUnhealthy = "unhealthy"
+)
+
+// health is the body of /healthz.
+type health struct {
+	State         string  `json:"state"`
+	Time          string  `json:"time,omitempty"`
+	DurationUnit  string  `json:"durationUnit"`
+	Duration      float64 `json:"duration"`
+	DurationNs    int64   `json:"durationNs"`
+	SoftThreshold float64 `json:"softThreshold"`
+	HardThreshold float64 `json:"hardThreshold"`
+	Error         string  `json:"error,omitempty"`
+}
+
+var (
+	lastResultMu sync.Mutex
+	lastResult   *relistResult
+)
+
+// setLastResult records the latest relist of the watch for /healthz.
+func setLastResult(result *relistResult) {
+	lastResultMu.Lock()
+	defer lastResultMu.Unlock()
+	lastResult = result
+}
+
+// getHealth tells whether the runtime is healthy, or degraded but still
+// working, from the latest relist.
+func getHealth() *health {
+	lastResultMu.Lock()
+	result := lastResult
+	lastResultMu.Unlock()
+
+	h := &health{
+		State:         healthStarting,
+		DurationUnit:  durationUnit,
+		SoftThreshold: durationValue(relistThreshold),
+		HardThreshold: durationValue(relistHardThreshold),
+	}
+	if result == nil {
+		return h
+	}
+	h.Time = formatTime(result.Time)
+	h.Duration, h.DurationNs = durationValue(result.Duration), int64(result.Duration)
+	switch {
+	case result.Err != nil:
+		h.State, h.Error = healthUnhealthy, result.Err.Error()
+	case result.Duration > relistHardThreshold:
+		h.State = healthUnhealthy
+	case result.Duration > relistThreshold:
+		h.State = healthDegraded
+	default:
+		h.State = healthHealthy
+	}
+	return h
+}
+
+// serveHealthz answers 503 only when the runtime is unhealthy, so a liveness
+// probe does not restart the watch while the runtime is merely slow.
+func serveHealthz(w http.ResponseWriter, r *http.Request) {
+	h := getHealth()
+	w.Header().Set("Content-Type", "application/json")
+	if h.State == healthUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h)
+}
+
+// startHTTPServer serves the endpoints of the watch in the background.
+func startHTTPServer(rs *runtimeService) {
+	if listenAddress == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", serveHealthz)
+	mux.HandleFunc("/events", serveEvents)
+	mux.HandleFunc("/heatmap", func(w http.ResponseWriter, r *http.Request) {
+		if err := writeHeatmaps(w, rs.Stats.heatmaps()); err != nil {
+			klog.Errorf("Write heatmaps failed: %v", err)
+		}
+	})
+	go func() {
+		klog.Fatal(http.ListenAndServe(listenAddress, mux))
+	}()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// imagesTop is the number of largest images reported by the images command.
+	imagesTop = 10
+	// slowImageStatus reports images whose verbose status takes longer than this.
+	slowImageStatus = time.Second
+)
+
+type imageReport struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+	Size uint64   `json:"size"`
+	// Containers is the number of containers using the image.
+	Containers int `json:"containers"`
+	// Status is the verbose status of the images of running containers.
+	Status *imageStatusReport `json:"status,omitempty"`
+}
+
+// imageStatusReport is the verbose ImageStatus of an image. The layers come
+// from the image config and the snapshotter from the runtime, if it tells.
+type imageStatusReport struct {
+	Latency     float64 `json:"latency"`
+	LatencyNs   int64   `json:"latencyNs"`
+	Slow        bool    `json:"slow"`
+	ChainID     string  `json:"chainID,omitempty"`
+	Snapshotter string  `json:"snapshotter,omitempty"`
+	Layers      int     `json:"layers"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// imageInfo is the part of the verbose info of ImageStatus we use.
+type imageInfo struct {
+	ChainID     string `json:"chainID"`
+	Snapshotter string `json:"snapshotter"`
+	ImageSpec   *struct {
+		RootFS *struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	} `json:"imageSpec"`
+}
+
+// imageStatus gets the verbose status of an image, errors are reported in the status.
+func (rs *runtimeService) imageStatus(id string) *imageStatusReport {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	now := rs.Clock.Now()
+	resp, err := rs.Images.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{
+		Image:   &runtimeapi.ImageSpec{Image: id},
+		Verbose: true,
+	})
+	elapsed := rs.Clock.Since(now)
+	r := &imageStatusReport{
+		Latency:   durationValue(elapsed),
+		LatencyNs: int64(elapsed),
+		Slow:      slowImageStatus > 0 && elapsed > slowImageStatus,
+	}
+	if r.Slow {
+		klog.Warningf("ImageStatus of %s took %v", id, elapsed)
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	info := &imageInfo{}
+	if data, ok := resp.Info["info"]; ok {
+		if err := json.Unmarshal([]byte(data), info); err != nil {
+			r.Error = fmt.Sprintf("parse verbose info: %v", err)
+			return r
+		}
+	}
+	r.ChainID, r.Snapshotter = info.ChainID, info.Snapshotter
+	if info.ImageSpec != nil && info.ImageSpec.RootFS != nil {
+		r.Layers = len(info.ImageSpec.RootFS.DiffIDs)
+	}
+	return r
+}
+
+// imagesReport is the analysis of the image store of the runtime.
+type imagesReport struct {
+	Count        int    `json:"count"`
+	Bytes        uint64 `json:"bytes"`
+	DurationUnit string `json:"durationUnit"`
+	// List is the latency of ListImages, ListPer1000 the same per 1000
+	// images since it grows with the size of the image store.
+	List          float64        `json:"list"`
+	ListNs        int64          `json:"listNs"`
+	ListPer1000   float64        `json:"listPer1000"`
+	ListPer1000Ns int64          `json:"listPer1000Ns"`
+	Largest       []*imageReport `json:"largest"`
+	// Unused are the images without containers, sandbox images included
+	// since the CRI does not tell the image of a sandbox.
+	Unused []*imageReport `json:"unused"`
+	// Running are the images of running containers, with their verbose status.
+	Running []*imageReport `json:"running"`
+}
+
+func (rs *runtimeService) listImages() ([]*runtimeapi.Image, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	now := rs.Clock.Now()
+	resp, err := rs.Images.ListImages(ctx, &runtimeapi.ListImagesRequest{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Images, rs.Clock.Since(now), nil
+}
+
+// imageUsers counts the containers of every image and tells whether some are
+// running, by image ID.
+func imageUsers(images []*runtimeapi.Image, containers []*runtimeapi.Container) (map[string]int, map[string]bool) {
+	refs := imageRefs(images)
+	users, running := make(map[string]int), make(map[string]bool)
+	for _, c := range containers {
+		if img := refs.resolve(c); img != nil {
+			users[img.Id]++
+			running[img.Id] = running[img.Id] || c.State == runtimeapi.ContainerState_CONTAINER_RUNNING
+		}
+	}
+	return users, running
+}
+
+// imageRefMap is the images by their IDs, tags and digests.
+type imageRefMap map[string]*runtimeapi.Image
+
+func imageRefs(images []*runtimeapi.Image) imageRefMap {
+	refs := make(imageRefMap)
+	for _, img := range images {
+		refs[img.Id] = img
+		for _, tag := range img.RepoTags {
+			refs[tag] = img
+		}
+		for _, digest := range img.RepoDigests {
+			refs[digest] = img
+		}
+	}
+	return refs
+}
+
+// resolve returns the image of a container, nil if it is not listed.
+func (refs imageRefMap) resolve(c *runtimeapi.Container) *runtimeapi.Image {
+	if img, ok := refs[c.ImageRef]; ok {
+		return img
+	}
+	return refs[c.GetImage().GetImage()]
+}
+
+func (rs *runtimeService) newImagesReport(images []*runtimeapi.Image, containers []*runtimeapi.Container, list time.Duration) *imagesReport {
+	users, running := imageUsers(images, containers)
+	r := &imagesReport{
+		Count:        len(images),
+		DurationUnit: durationUnit,
+		List:         durationValue(list),
+		ListNs:       int64(list),
+		Largest:      []*imageReport{},
+		Unused:       []*imageReport{},
+		Running:      []*imageReport{},
+	}
+	if len(images) > 0 {
+		per1000 := list * 1000 / time.Duration(len(images))
+		r.ListPer1000, r.ListPer1000Ns = durationValue(per1000), int64(per1000)
+	}
+	var all []*imageReport
+	for _, img := range images {
+		i := &imageReport{ID: img.Id, Tags: img.RepoTags, Size: img.Size_, Containers: users[img.Id]}
+		r.Bytes += img.Size_
+		// Slow metadata of the images in use often precedes slow container starts.
+		if running[img.Id] {
+			i.Status = rs.imageStatus(img.Id)
+			r.Running = append(r.Running, i)
+		}
+		all = append(all, i)
+		if i.Containers == 0 {
+			r.Unused = append(r.Unused, i)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Size > all[j].Size })
+	if len(all) > imagesTop {
+		all = all[:imagesTop]
+	}
+	r.Largest = append(r.Largest, all...)
+	return r
+}
+
+func (r *imageReport) name() string {
+	if len(r.Tags) > 0 {
+		return strings.Join(r.Tags, ",")
+	}
+	return "<none>"
+}
+
+func printImages(out io.Writer, r *imagesReport) {
+	fmt.Fprintf(out, "Images: %d, %.1fMiB, ListImages: %s (%s per 1000 images)\n\n", r.Count, float64(r.Bytes)/(1<<20), formatDuration(time.Duration(r.ListNs)), formatDuration(time.Duration(r.ListPer1000Ns)))
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LARGEST\tID\tSIZE\tCONTAINERS")
+	for _, i := range r.Largest {
+		fmt.Fprintf(w, "%s\t%s\t%.1fMiB\t%d\n", i.name(), i.ID, float64(i.Size)/(1<<20), i.Containers)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RUNNING\tID\tSTATUS LATENCY\tLAYERS\tSNAPSHOTTER\tCHAIN ID")
+	for _, i := range r.Running {
+		latency := formatDuration(time.Duration(i.Status.LatencyNs))
+		if i.Status.Slow {
+			latency += " (slow)"
+		}
+		if i.Status.Error != "" {
+			latency += " " + i.Status.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", i.name(), i.ID, latency, i.Status.Layers, i.Status.Snapshotter, i.Status.ChainID)
+	}
+	w.Flush()
+	if len(r.Unused) > 0 {
+		fmt.Fprintln(out)
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "UNUSED\tID\tSIZE")
+		for _, i := range r.Unused {
+			fmt.Fprintf(w, "%s\t%s\t%.1fMiB\n", i.name(), i.ID, float64(i.Size)/(1<<20))
+		}
+		w.Flush()
+	}
+}
+
+// imagesCommand analyzes the image store, since a bloated one slows down
+// several runtime paths.
+func imagesCommand(rs *runtimeService) error {
+	images, list, err := rs.listImages()
+	if err != nil {
+		return err
+	}
+	containers, err := rs.getKubeletContainers("", true)
+	if err != nil {
+		return err
+	}
+	r := rs.newImagesReport(images, containers, list)
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	printImages(os.Stdout, r)
+	return nil
+}
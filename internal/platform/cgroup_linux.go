@@ -0,0 +1,108 @@
+package platform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type cgroupInspector struct {
+	root     string
+	procRoot string
+}
+
+func (c *cgroupInspector) Cgroup(cgroupsPath string, pid int) (*Cgroup, error) {
+	path := resolveCgroupPath(cgroupsPath)
+	if path == "" && pid > 0 {
+		path = c.procCgroupPath(pid)
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	cg := &Cgroup{Dir: filepath.Join(c.root, path), FreezerDir: filepath.Join(c.root, path)}
+	v2 := IsCgroupV2(c.root)
+	if !v2 {
+		cg.Dir, cg.FreezerDir = filepath.Join(c.root, "pids", path), filepath.Join(c.root, "freezer", path)
+	}
+	if _, err := os.Stat(cg.Dir); err != nil {
+		return cg, err
+	}
+	procs, err := ioutil.ReadFile(filepath.Join(cg.Dir, "cgroup.procs"))
+	if err != nil {
+		return cg, err
+	}
+	cg.Procs = len(strings.Fields(string(procs)))
+	cg.Frozen = cgroupFrozen(cg.FreezerDir, v2)
+	return cg, nil
+}
+
+// resolveCgroupPath converts the cgroupsPath of an OCI runtime spec into a path
+// relative to the cgroup hierarchy. The systemd driver uses the form
+// "slice:prefix:name", e.g. "kubepods-burstable-pod1.slice:cri-containerd:abc".
+func resolveCgroupPath(cgroupsPath string) string {
+	parts := strings.Split(cgroupsPath, ":")
+	if len(parts) != 3 {
+		return cgroupsPath
+	}
+	slice, prefix, name := parts[0], parts[1], parts[2]
+
+	// Every dash in a slice name denotes a parent slice.
+	var dirs []string
+	if slice != "" && slice != "-.slice" {
+		prefixes := strings.Split(strings.TrimSuffix(slice, ".slice"), "-")
+		for i := range prefixes {
+			dirs = append(dirs, strings.Join(prefixes[:i+1], "-")+".slice")
+		}
+	}
+	dirs = append(dirs, prefix+"-"+name+".scope")
+	return "/" + strings.Join(dirs, "/")
+}
+
+// procCgroupPath reads the cgroup of a process, preferring the unified hierarchy.
+func (c *cgroupInspector) procCgroupPath(pid int) string {
+	data, err := ioutil.ReadFile(filepath.Join(c.procRoot, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	var path string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" || strings.Contains(fields[1], "pids") {
+			path = fields[2]
+		}
+	}
+	return path
+}
+
+// IsCgroupV2 reports whether the cgroup hierarchy mounted at root is unified.
+func IsCgroupV2(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+func cgroupFrozen(dir string, v2 bool) bool {
+	if v2 {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.events"))
+		if err != nil {
+			return false
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "frozen 1" {
+				return true
+			}
+		}
+		return false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "freezer.state"))
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) != "THAWED"
+}
@@ -0,0 +1,47 @@
+package platform
+
+import (
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"path/filepath"
+)
+
+// dirScanner walks the log directory of every pod.
+type dirScanner struct{}
+
+func (dirScanner) Scan(root string) ([]*LogDirUsage, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*LogDirUsage
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		usage := &LogDirUsage{Dir: entry.Name()}
+		err := filepath.Walk(filepath.Join(root, entry.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// The runtime may rotate or remove logs while we walk.
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			usage.Files++
+			usage.Bytes += info.Size()
+			if usage.Oldest.IsZero() || info.ModTime().Before(usage.Oldest) {
+				usage.Oldest = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			klog.Errorf("Scan log directory %s failed: %v", entry.Name(), err)
+			continue
+		}
+		result = append(result, usage)
+	}
+	return result, nil
+}
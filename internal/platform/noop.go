@@ -0,0 +1,31 @@
+package platform
+
+type noopProcInspector struct{}
+
+func (noopProcInspector) Process(int) (*Process, error) {
+	return nil, ErrUnsupported
+}
+
+func (noopProcInspector) Namespace(int, string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (noopProcInspector) Usage(int) (*Usage, error) {
+	return nil, ErrUnsupported
+}
+
+func (noopProcInspector) Commands() (map[string]int, error) {
+	return nil, ErrUnsupported
+}
+
+type noopCgroupInspector struct{}
+
+func (noopCgroupInspector) Cgroup(string, int) (*Cgroup, error) {
+	return nil, ErrUnsupported
+}
+
+type noopLogDirScanner struct{}
+
+func (noopLogDirScanner) Scan(string) ([]*LogDirUsage, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,89 @@
+// Package platform hides the OS specific diagnostics of oncepleg behind
+// interfaces, so that the CRI checks build on every OS and the process, cgroup
+// and log directory checks degrade gracefully where they are not available.
+package platform
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// ErrUnsupported is returned by the inspectors of an OS without an implementation.
+var ErrUnsupported = errors.New("not supported on " + runtime.GOOS)
+
+// Process is the state of a container process.
+type Process struct {
+	Pid   int
+	Comm  string
+	State string
+	// Threads is empty for zombies.
+	Threads []*Thread
+}
+
+// Thread is the state of one thread of a container process.
+type Thread struct {
+	Tid   int
+	Comm  string
+	State string
+	// Wchan is only read for threads in uninterruptible sleep.
+	Wchan string
+}
+
+// Usage is the resource usage of a process, the values that can not be read
+// are -1.
+type Usage struct {
+	CPUTime time.Duration
+	// RSS is measured in bytes.
+	RSS     int64
+	Threads int
+	// Start identifies the start of the process, a process restarted with the
+	// same pid has another one.
+	Start      uint64
+	OpenFDs    int
+	MaxOpenFDs int
+}
+
+// ProcInspector inspects the processes of containers, of the runtime daemon
+// and of oncepleg itself.
+type ProcInspector interface {
+	Process(pid int) (*Process, error)
+	// Namespace identifies the namespace of kind, e.g. pid or net, which pid
+	// is in. Processes in the same namespace get the same identity.
+	Namespace(pid int, kind string) (string, error)
+	Usage(pid int) (*Usage, error)
+	// Commands returns the oldest process of every command name.
+	Commands() (map[string]int, error)
+}
+
+// Cgroup is the state of the cgroup of a container.
+type Cgroup struct {
+	// Dir is the directory of the pids controller.
+	Dir   string
+	Procs int
+	// FreezerDir is the directory of the freezer controller.
+	FreezerDir string
+	Frozen     bool
+}
+
+// CgroupInspector inspects the cgroups of containers.
+type CgroupInspector interface {
+	// Cgroup resolves the cgroup from the cgroupsPath of the OCI runtime spec
+	// and falls back to the cgroup of pid. It returns nil if neither is known,
+	// and an error satisfying os.IsNotExist along with Dir if it is missing.
+	Cgroup(cgroupsPath string, pid int) (*Cgroup, error)
+}
+
+// LogDirUsage is the disk usage of the log directory of one pod.
+type LogDirUsage struct {
+	// Dir is named <namespace>_<name>_<uid>.
+	Dir    string
+	Files  int
+	Bytes  int64
+	Oldest time.Time
+}
+
+// LogDirScanner measures the log directory of every pod.
+type LogDirScanner interface {
+	Scan(root string) ([]*LogDirUsage, error)
+}
@@ -0,0 +1,17 @@
+package platform
+
+// NewProcInspector returns the /proc based inspector.
+func NewProcInspector(procRoot string) ProcInspector {
+	return &procInspector{root: procRoot}
+}
+
+// NewCgroupInspector returns the cgroupfs based inspector, procRoot is needed to
+// read the cgroup of processes.
+func NewCgroupInspector(cgroupRoot, procRoot string) CgroupInspector {
+	return &cgroupInspector{root: cgroupRoot, procRoot: procRoot}
+}
+
+// NewLogDirScanner returns the scanner of the CRI log directory.
+func NewLogDirScanner() LogDirScanner {
+	return dirScanner{}
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package platform
+
+// NewProcInspector returns an inspector that always fails with ErrUnsupported.
+func NewProcInspector(string) ProcInspector {
+	return noopProcInspector{}
+}
+
+// NewCgroupInspector returns an inspector that always fails with ErrUnsupported.
+func NewCgroupInspector(string, string) CgroupInspector {
+	return noopCgroupInspector{}
+}
+
+// NewLogDirScanner returns a scanner that always fails with ErrUnsupported,
+// there is no kubelet on the development machines oncepleg is built for here.
+func NewLogDirScanner() LogDirScanner {
+	return noopLogDirScanner{}
+}
@@ -0,0 +1,19 @@
+package platform
+
+// NewProcInspector returns an inspector that always fails with ErrUnsupported,
+// Windows containers have no /proc.
+func NewProcInspector(string) ProcInspector {
+	return noopProcInspector{}
+}
+
+// NewCgroupInspector returns an inspector that always fails with ErrUnsupported,
+// Windows containers are isolated with job objects instead of cgroups.
+func NewCgroupInspector(string, string) CgroupInspector {
+	return noopCgroupInspector{}
+}
+
+// NewLogDirScanner returns the scanner of the CRI log directory, which kubelet
+// lays out the same way as on Linux.
+func NewLogDirScanner() LogDirScanner {
+	return dirScanner{}
+}
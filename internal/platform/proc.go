@@ -0,0 +1,58 @@
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// procStat is the subset of /proc/<pid>/stat we care about.
+type procStat struct {
+	Pid   int
+	Comm  string
+	State string
+	// Utime and Stime are measured in clock ticks.
+	Utime      uint64
+	Stime      uint64
+	NumThreads int
+	// StartTime is measured in clock ticks after system boot.
+	StartTime uint64
+	// RSS is measured in pages.
+	RSS int64
+}
+
+// readProcStat parses a stat file of proc(5).
+func readProcStat(path string) (*procStat, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// comm is wrapped in parentheses and may itself contain spaces or parentheses,
+	// so split around the last closing one.
+	s := string(data)
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("malformed stat %s", path)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return nil, err
+	}
+	// fields[0] is the third field of the file, see proc(5).
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat %s", path)
+	}
+	stat := &procStat{
+		Pid:   pid,
+		Comm:  s[open+1 : end],
+		State: fields[0],
+	}
+	stat.Utime, _ = strconv.ParseUint(fields[11], 10, 64)
+	stat.Stime, _ = strconv.ParseUint(fields[12], 10, 64)
+	stat.NumThreads, _ = strconv.Atoi(fields[17])
+	stat.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	stat.RSS, _ = strconv.ParseInt(fields[21], 10, 64)
+	return stat, nil
+}
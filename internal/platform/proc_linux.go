@@ -0,0 +1,130 @@
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clockTicks is USER_HZ, which is 100 on every platform Kubernetes supports.
+	clockTicks = 100
+	pageSize   = 4096
+)
+
+type procInspector struct {
+	root string
+}
+
+func (p *procInspector) Process(pid int) (*Process, error) {
+	dir := filepath.Join(p.root, strconv.Itoa(pid))
+	stat, err := readProcStat(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	proc := &Process{Pid: pid, Comm: stat.Comm, State: stat.State}
+	if stat.State == "Z" {
+		return proc, nil
+	}
+
+	tasks, err := ioutil.ReadDir(filepath.Join(dir, "task"))
+	if err != nil {
+		return nil, fmt.Errorf("list tasks: %v", err)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		ts, err := readProcStat(filepath.Join(dir, "task", task.Name(), "stat"))
+		if err != nil {
+			// The thread exited while we listed them.
+			continue
+		}
+		thread := &Thread{Tid: tid, Comm: ts.Comm, State: ts.State}
+		if ts.State == "D" {
+			if data, err := ioutil.ReadFile(filepath.Join(dir, "task", task.Name(), "wchan")); err == nil {
+				thread.Wchan = string(data)
+			}
+		}
+		proc.Threads = append(proc.Threads, thread)
+	}
+	return proc, nil
+}
+
+// Namespace reads the link of the namespace, e.g. pid:[4026531836].
+func (p *procInspector) Namespace(pid int, kind string) (string, error) {
+	return os.Readlink(filepath.Join(p.root, strconv.Itoa(pid), "ns", kind))
+}
+
+func (p *procInspector) Usage(pid int) (*Usage, error) {
+	dir := filepath.Join(p.root, strconv.Itoa(pid))
+	stat, err := readProcStat(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	u := &Usage{
+		CPUTime:    time.Duration(stat.Utime+stat.Stime) * time.Second / clockTicks,
+		RSS:        stat.RSS * pageSize,
+		Threads:    stat.NumThreads,
+		Start:      stat.StartTime,
+		OpenFDs:    -1,
+		MaxOpenFDs: readOpenFilesLimit(filepath.Join(dir, "limits")),
+	}
+	if fds, err := ioutil.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		u.OpenFDs = len(fds)
+	}
+	return u, nil
+}
+
+// readOpenFilesLimit returns the soft limit of open files from /proc/<pid>/limits.
+func readOpenFilesLimit(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			return -1
+		}
+		v, err := strconv.Atoi(fields[0])
+		if err != nil {
+			// "unlimited"
+			return -1
+		}
+		return v
+	}
+	return -1
+}
+
+func (p *procInspector) Commands() (map[string]int, error) {
+	entries, err := ioutil.ReadDir(p.root)
+	if err != nil {
+		return nil, err
+	}
+	pids := make(map[string]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		comm, err := ioutil.ReadFile(filepath.Join(p.root, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		// Keep the oldest process, shims and children are started later.
+		name := strings.TrimSpace(string(comm))
+		if oldest, found := pids[name]; !found || pid < oldest {
+			pids[name] = pid
+		}
+	}
+	return pids, nil
+}
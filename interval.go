@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+var (
+	// adaptiveInterval scales the interval of the watch with the number of
+	// containers, like proposed for the relist period of the kubelet.
+	adaptiveInterval = false
+	// intervalPer100Containers is added to watchInterval per 100 containers.
+	intervalPer100Containers = 10 * time.Millisecond
+	// startJitter delays the first relist of the watch by up to this long,
+	// so nodes started together do not relist and notify together.
+	startJitter time.Duration
+	// intervalJitter adds up to this fraction of the interval to every period.
+	intervalJitter = 0.0
+)
+
+var (
+	// currentInterval is the period between relists of the watch.
+	currentInterval time.Duration
+	// intervalFactor slows down the watch when it is over its budget.
+	intervalFactor = 1
+)
+
+// relistInterval is the period after a relist of the given number of containers.
+func relistInterval(containers int) time.Duration {
+	d := watchInterval
+	if adaptiveInterval {
+		d += intervalPer100Containers * time.Duration(containers) / 100
+	}
+	d *= time.Duration(intervalFactor)
+	if intervalJitter > 0 {
+		d += time.Duration(rand.Float64() * intervalJitter * float64(d))
+	}
+	return d
+}
+
+// sleepStartJitter waits a random part of startJitter. It seeds the jitter
+// so that nodes do not share the same sequence.
+func sleepStartJitter() {
+	rand.Seed(time.Now().UnixNano())
+	if startJitter <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(rand.Int63n(int64(startJitter))))
+}
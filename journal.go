@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"k8s.io/klog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var (
+	// correlateJournal looks for garbage collection and compaction events of
+	// containerd in journald during relists breaching the threshold.
+	correlateJournal = true
+	journalTimeout   = 10 * time.Second
+)
+
+// containerdMaintenance are log messages of containerd maintenance work which
+// holds the metadata database lock and slows down every CRI call.
+var containerdMaintenance = []struct {
+	match string
+	cause string
+}{
+	{"garbage collected", "metadata garbage collection"},
+	{"gc scheduler", "metadata garbage collection"},
+	{"snapshot garbage", "snapshot garbage collection"},
+	{"remove snapshot", "snapshot removal"},
+	{"compact", "boltdb compaction"},
+}
+
+// journalCauses returns the containerd maintenance events logged between
+// since and until, as likely causes of a slow relist.
+func journalCauses(since, until time.Time) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), journalTimeout)
+	defer cancel()
+	const layout = "2006-01-02 15:04:05"
+	out, err := exec.CommandContext(ctx, "journalctl", "-u", "containerd", "--no-pager", "-o", "short-iso",
+		"--since", since.Add(-time.Second).Format(layout), "--until", until.Add(time.Second).Format(layout)).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var causes []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.ToLower(scanner.Text())
+		for _, m := range containerdMaintenance {
+			if strings.Contains(line, m.match) && !seen[m.cause] {
+				seen[m.cause] = true
+				causes = append(causes, m.cause)
+			}
+		}
+	}
+	return causes, scanner.Err()
+}
+
+// annotateCauses adds the likely causes of a relist breaching the threshold.
+func annotateCauses(result *relistResult, daemon *runtimeDaemon) {
+	if !correlateJournal || daemon == nil || daemon.Comm != "containerd" || result.Duration <= relistThreshold {
+		return
+	}
+	causes, err := journalCauses(result.Time, result.Time.Add(result.Duration))
+	if err != nil {
+		klog.V(2).Infof("Read containerd journal failed: %v", err)
+		return
+	}
+	for _, cause := range causes {
+		klog.Warningf("Relist took %v during containerd %s", result.Duration, cause)
+	}
+	result.Causes = causes
+}
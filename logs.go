@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/coderwangke/oncepleg/internal/platform"
+	"k8s.io/klog"
+	"sort"
+	"time"
+)
+
+var (
+	podLogsDir = "/var/log/pods"
+	logDirs    = platform.NewLogDirScanner()
+
+	// scanLogs enables the scan of the CRI log directory.
+	scanLogs        = false
+	logWarningFiles = 100
+	logWarningBytes = int64(1 << 30)
+)
+
+// runLogScan reports the pods with enormous or extremely numerous log files,
+// which slow down runtime operations and disk GC.
+func runLogScan(c clock) {
+	usages, err := logDirs.Scan(podLogsDir)
+	if err != nil {
+		klog.Errorf("Scan log directory %s failed: %v", podLogsDir, err)
+		return
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Bytes > usages[j].Bytes })
+
+	var files int
+	var bytes int64
+	for _, u := range usages {
+		files += u.Files
+		bytes += u.Bytes
+		age := time.Duration(0)
+		if !u.Oldest.IsZero() {
+			age = c.Since(u.Oldest).Round(time.Second)
+		}
+		if u.Files > logWarningFiles || u.Bytes > logWarningBytes {
+			klog.Warningf("Pod logs %s: %d files, %.1fMiB, oldest %v ago", u.Dir, u.Files, float64(u.Bytes)/(1<<20), age)
+			continue
+		}
+		klog.V(4).Infof("Pod logs %s: %d files, %.1fMiB, oldest %v ago", u.Dir, u.Files, float64(u.Bytes)/(1<<20), age)
+	}
+	klog.V(2).Infof("Pod logs in %s: %d pods, %d files, %.1fMiB", podLogsDir, len(usages), files, float64(bytes)/(1<<20))
+}
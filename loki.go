@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const lokiPushPath = "/loki/api/v1/push"
+
+// lokiTenant is sent as X-Scope-OrgID to multi-tenant Loki.
+var lokiTenant = ""
+
+// lokiStream is a stream of the Loki push API, values are pairs of a unix
+// nanoseconds timestamp and a line.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiStreams groups the lines of a push by their labels.
+type lokiStreams struct {
+	streams map[string]*lokiStream
+	order   []string
+}
+
+func (s *lokiStreams) add(labels map[string]string, ns int64, line interface{}) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	key := labels["kind"] + "/" + labels["namespace"]
+	stream, found := s.streams[key]
+	if !found {
+		stream = &lokiStream{Stream: labels}
+		s.streams[key] = stream
+		s.order = append(s.order, key)
+	}
+	stream.Values = append(stream.Values, [2]string{strconv.FormatInt(ns, 10), string(data)})
+	return nil
+}
+
+func lokiLabels(kind, namespace string) map[string]string {
+	labels := map[string]string{"job": "oncepleg", "node": nodeName, "kind": kind}
+	if namespace != "" {
+		labels["namespace"] = namespace
+	}
+	return labels
+}
+
+// pushLoki pushes a relist to the Loki push API at url: the relist without
+// its pods and events, a line per pod labeled with its namespace and a line
+// per PLEG event labeled with the namespace of its pod when known.
+func pushLoki(url string, result *relistResult) error {
+	if !strings.HasSuffix(url, lokiPushPath) {
+		url = strings.TrimSuffix(url, "/") + lokiPushPath
+	}
+
+	r := newReport(result)
+	s := &lokiStreams{streams: make(map[string]*lokiStream)}
+	namespaces := make(map[string]string)
+	for _, pod := range r.Pods {
+		namespaces[pod.ID] = pod.Namespace
+		if err := s.add(lokiLabels("pod", pod.Namespace), result.Time.UnixNano(), pod); err != nil {
+			return err
+		}
+	}
+	for i, e := range r.Events {
+		if err := s.add(lokiLabels("event", namespaces[e.PodID]), result.Events[i].Time.UnixNano(), e); err != nil {
+			return err
+		}
+	}
+	r.Pods, r.Events = nil, nil
+	if err := s.add(lokiLabels("relist", ""), result.Time.UnixNano(), r); err != nil {
+		return err
+	}
+
+	var push struct {
+		Streams []*lokiStream `json:"streams"`
+	}
+	for _, key := range s.order {
+		push.Streams = append(push.Streams, s.streams[key])
+	}
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if lokiTenant != "" {
+		req.Header.Set("X-Scope-OrgID", lokiTenant)
+	}
+	resp, err := (&http.Client{Timeout: notifyTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("loki responded %s: %s", resp.Status, msg)
+	}
+	return nil
+}
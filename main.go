@@ -4,31 +4,310 @@ import (
 	"flag"
 	"k8s.io/klog"
 	"os"
+	"strings"
 )
 
 func main() {
-	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
-	klog.InitFlags(klogFlags)
-	klogFlags.Set("v", "2")
-	klogFlags.Set("logtostderr", "true")
-	klogFlags.Set("skip_headers", "true")
-	klogFlags.Parse(os.Args[1:])
+	// The first argument is an optional command, relist by default.
+	args, command := os.Args[1:], ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("oncepleg", flag.ExitOnError)
+	klog.InitFlags(flags)
+	flags.Set("v", "2")
+	flags.Set("logtostderr", "true")
+	flags.Set("skip_headers", "true")
+	flags.BoolVar(&inspectProcesses, "inspect-processes", inspectProcesses, "Inspect container processes under /proc and report zombies or processes stuck in D state. Requires verbose ContainerStatus support from the runtime.")
+	flags.BoolVar(&inspectCgroups, "inspect-cgroups", inspectCgroups, "Verify the cgroup of every container exists under /sys/fs/cgroup and report missing, empty or frozen cgroups. Requires verbose ContainerStatus support from the runtime.")
+	flags.Var(activeWindowsFlag{}, "active-windows", "Only run the intrusive checks, -disk-check, -check-runtime-config and the smoke command, within these daily windows of local time, e.g. 01:00-05:00,22:00-23:30.")
+	flags.Float64Var(&activeMaxLoad, "active-max-load", activeMaxLoad, "Skip the intrusive checks while the 1 minute load average is above this.")
+	flags.BoolVar(&inspectNamespaces, "inspect-namespaces", inspectNamespaces, "Verify the PID namespace of every ready sandbox matches the mode the kubelet asked for, in the runtime spec and, on Linux, under /proc. Requires verbose PodSandboxStatus support from the runtime.")
+	flags.BoolVar(&diskCheck, "disk-check", diskCheck, "Measure small-write/fsync latency on the runtime state and root directories after the relist.")
+	flags.StringVar(&diskCheckDirs, "disk-check-dirs", diskCheckDirs, "Comma separated directories for -disk-check, defaults to the known directories of the detected runtime.")
+	flags.IntVar(&diskCheckSamples, "disk-check-samples", diskCheckSamples, "Number of write/fsync samples per directory for -disk-check.")
+	flags.BoolVar(&scanLogs, "scan-logs", scanLogs, "Scan the CRI log directory for the file count, size and age of every pod's logs.")
+	flags.StringVar(&podLogsDir, "pod-logs-dir", podLogsDir, "The CRI log directory for -scan-logs.")
+	flags.IntVar(&logWarningFiles, "log-warning-files", logWarningFiles, "Report pods having more log files than this for -scan-logs.")
+	flags.Int64Var(&logWarningBytes, "log-warning-bytes", logWarningBytes, "Report pods having more bytes of logs than this for -scan-logs.")
+	flags.BoolVar(&checkPodDirs, "check-pod-dirs", checkPodDirs, "Compare the pod directories of the kubelet with the sandboxes of the runtime and report orphans.")
+	flags.StringVar(&kubeletPodsDir, "kubelet-pods-dir", kubeletPodsDir, "The kubelet pods directory for -check-pod-dirs, and for the gc command which only collects the newest sandbox of pods without a directory.")
+	flags.StringVar(&findName, "name", findName, "Regexp the find command matches against the names of containers and sandboxes, the name of a sandbox is the name of its pod.")
+	flags.StringVar(&findImage, "image", findImage, "Regexp the find command matches against the tags, digests and IDs of the images of containers.")
+	flags.Var(labelMatchersFlag{}, "label", "A key=regexp the find command matches against the labels of containers and sandboxes. Repeat to require several labels.")
+	flags.IntVar(&filterRounds, "filter-rounds", filterRounds, "The number of times the filter-efficacy command repeats its measurement.")
+	flags.StringVar(&shellScript, "script", shellScript, "Run the shell commands of this file one after another instead of reading them from stdin, e.g. for runbooks. With -output json the results make one report.")
+	flags.BoolVar(&dryRun, "dry-run", dryRun, "Print the CRI calls the relist would make with the given flags, with their filters, and exit without connecting to the runtime.")
+	flags.DurationVar(&watchInterval, "watch", watchInterval, "Relist periodically with this interval instead of only once.")
+	flags.Var(thresholdsFlag{}, "thresholds", "Latency SLOs of CRI methods with their severity, e.g. ListContainers=300ms,ContainerStatus=100ms:critical,relist=1s. The slowest call of a method in every relist is checked.")
+	flags.Float64Var(&baselineFactor, "baseline-factor", baselineFactor, "Learn the thresholds of the relist and of every CRI method from the node itself, breaching at this multiple of their p95 over -baseline-window. The static thresholds apply while learning.")
+	flags.DurationVar(&baselineWindow, "baseline-window", baselineWindow, "The trailing window of -baseline-factor.")
+	flags.IntVar(&baselineMinSamples, "baseline-min-samples", baselineMinSamples, "The number of relists -baseline-factor learns from before replacing the static thresholds.")
+	flags.IntVar(&baselineMaxSamples, "baseline-max-samples", baselineMaxSamples, "The number of latencies -baseline-factor keeps per check, 32 bytes each. A baseline half full keeps the latencies evenly spaced, so it still spans -baseline-window at a lower resolution without weighing the recent ones more. Zero keeps them all.")
+	flags.StringVar(&baselineFile, "baseline-file", baselineFile, "Persist the baselines of -baseline-factor to this file, so a restarted watch does not learn again.")
+	flags.DurationVar(&relistThreshold, "relist-threshold", relistThreshold, "A relist taking longer than this breaches the SLO and is notified, like the PLEG relist threshold of the kubelet.")
+	flags.StringVar(&nodeName, "node-name", nodeName, "The node name reported in notifications, defaults to $NODE_NAME or the hostname.")
+	flags.DurationVar(&alertFor, "alert-for", alertFor, "Only notify when the SLO has been breached for this long, requires -watch.")
+	flags.DurationVar(&alertCooldown, "alert-cooldown", alertCooldown, "Resolve a notification once the check has been healthy for this long.")
+	flags.StringVar(&webhookURL, "webhook-url", webhookURL, "Post a notification to this webhook when the relist SLO is breached.")
+	flags.StringVar(&webhookTemplate, "webhook-template", webhookTemplate, "The payload of -webhook-url, either a built-in template (slack, teams) or the path of a Go template file.")
+	flags.StringVar(&smtpServer, "smtp-server", smtpServer, "Email a notification through this SMTP server (host:port) when the relist SLO is breached.")
+	flags.StringVar(&smtpFrom, "smtp-from", smtpFrom, "The sender of -smtp-server emails.")
+	flags.StringVar(&smtpTo, "smtp-to", smtpTo, "Comma separated recipients of -smtp-server emails.")
+	flags.StringVar(&smtpUsername, "smtp-username", smtpUsername, "Authenticate to -smtp-server with this user.")
+	flags.StringVar(&smtpPasswordFile, "smtp-password-file", smtpPasswordFile, "The file holding the password of -smtp-username.")
+	flags.StringVar(&smtpSubject, "smtp-subject", smtpSubject, "The Go template of the email subject.")
+	flags.DurationVar(&smtpMinInterval, "smtp-min-interval", smtpMinInterval, "Send at most one firing email per check and interval, the others are counted in the next email. Recoveries are always sent.")
+	flags.StringVar(&pagerDutyRoutingKey, "pagerduty-routing-key", pagerDutyRoutingKey, "Trigger PagerDuty incidents with this Events API v2 routing key when the relist SLO is breached.")
+	flags.StringVar(&pagerDutyURL, "pagerduty-url", pagerDutyURL, "The PagerDuty Events API v2 endpoint.")
+	flags.Var(breachExecFlag{}, "on-breach-exec", "Run this command whenever the SLO is breached. Repeat it for every argument, each is a Go template of the breach, e.g. -on-breach-exec /usr/local/bin/capture.sh -on-breach-exec {{.RPC}} -on-breach-exec {{.Duration}}.")
+	flags.DurationVar(&onBreachTimeout, "on-breach-timeout", onBreachTimeout, "Kill the -on-breach-exec command after this long.")
+	flags.BoolVar(&noColor, "no-color", noColor, "Do not color the output, even when writing to a terminal.")
+	flags.BoolVar(&progress, "progress", progress, "Show the progress of the pod status phase when stderr is a terminal.")
+	flags.StringVar(&sortBy, "sort-by", sortBy, "Order the pods of the report by latency, name, namespace, containers or age.")
+	flags.Var(outputsFlag{}, "output", "The format of the report, table, tree or json, printed to stdout or appended to a file with format=path. loki=url pushes the pods and PLEG events to Loki, plugin=name runs a sink plugin. Repeat to write several outputs, e.g. -output table -output json=/var/log/oncepleg.json.")
+	flags.StringVar(&pluginDir, "plugin-dir", pluginDir, "The directory of the sink plugins of -output plugin=name, listed by the plugins command.")
+	flags.DurationVar(&pluginTimeout, "plugin-timeout", pluginTimeout, "Kill a sink plugin after this long.")
+	flags.StringVar(&lokiTenant, "loki-tenant", lokiTenant, "The tenant of -output loki=url, sent as X-Scope-OrgID.")
+	flags.Int64Var(&outputMaxBytes, "output-max-bytes", outputMaxBytes, "Rotate the files of -output format=path once they are larger than this, indexed in path.manifest.json. Zero never rotates. Can not be used with -run-as-user or -run-as-group, rotating writes to the directory of the output.")
+	flags.IntVar(&outputMaxFiles, "output-max-files", outputMaxFiles, "The number of rotated files kept of every output file.")
+	flags.BoolVar(&outputGzip, "output-gzip", outputGzip, "Compress the rotated output files with gzip.")
+	flags.StringVar(&readyFile, "ready-file", readyFile, "The file written by the gate command once a relist succeeded within -relist-threshold.")
+	flags.DurationVar(&gateTimeout, "gate-timeout", gateTimeout, "The gate command fails if the runtime is not responsive within this long.")
+	flags.StringVar(&terminationLog, "termination-log", terminationLog, "Write the summary of the relist and its breaches to this file on exit if it exists, the terminationMessagePath of the pod.")
+	flags.BoolVar(&oncePerBoot, "once-per-boot", oncePerBoot, "Exit immediately if a relist already succeeded since the node booted, for node startup scripts.")
+	flags.StringVar(&bootMarker, "boot-marker", bootMarker, "The file recording the boot ID of the last successful relist for -once-per-boot.")
+	flags.BoolVar(&printSchema, "schema", printSchema, "Print the JSON Schema of the json output and exit.")
+	flags.BoolVar(&quiet, "quiet", quiet, "Only print the report to stdout and errors to stderr, for use in scripts.")
+	flags.StringVar(&durationUnit, "duration-unit", durationUnit, "The unit of the durations of the report, ms or s.")
+	flags.BoolVar(&redact, "redact", redact, "Hash pod and container names, namespaces and images in the report, consistently within a run. Logs are not redacted, combine with -quiet.")
+	flags.BoolVar(&timeline, "timeline", timeline, "Print the created, started and finished times of every container after the pod table.")
+	flags.BoolVar(&parallelList, "parallel-list", parallelList, "Experimental: issue the ListPodSandbox and ListContainers calls of the list phase concurrently, which the kubelet does not, and report how much shorter the list phase is.")
+	flags.DurationVar(&hedgeAfter, "hedge-after", hedgeAfter, "Experimental: issue a second attempt of a ContainerStatus or PodSandboxStatus call still pending after this long and use whichever answers first, reporting how often the second attempt wins. Zero disables hedging.")
+	flags.DurationVar(&podStatusTimeout, "pod-status-timeout", podStatusTimeout, "Abandon the status calls of a pod after this long and continue the relist with the next pod, recording the pod as timed out. Zero waits however long they take.")
+	flags.DurationVar(&clockSkewThreshold, "clock-skew-threshold", clockSkewThreshold, "Report container timestamps further than this in the future of the host clock.")
+	flags.DurationVar(&slowStart, "slow-start", slowStart, "Report containers which took longer than this from created to started.")
+	flags.BoolVar(&showMounts, "mounts", showMounts, "Include the mounts of every container in the JSON report.")
+	flags.IntVar(&mountWarning, "mount-warning", mountWarning, "Report containers having more mounts than this, which slows down status calls on some runtimes.")
+	flags.BoolVar(&strict, "strict", strict, "Count sandboxes without metadata and containers without the io.kubernetes.* labels as conformance violations, and exit with -strict-exit-code if there are any.")
+	flags.IntVar(&strictExitCode, "strict-exit-code", strictExitCode, "The exit code of -strict when there are conformance violations.")
+	flags.StringVar(&smokeImage, "smoke-image", smokeImage, "The image of the smoke test container, pulled if missing.")
+	flags.DurationVar(&olderThan, "older-than", olderThan, "The minimum age of the containers and sandboxes found by the gc command.")
+	flags.BoolVar(&prune, "prune", prune, "Print the plan of the removals and remove the containers and sandboxes found by the gc command after confirmation.")
+	flags.StringVar(&gcStates, "state", gcStates, "Comma separated states the gc command is restricted to, exited for containers and notready for sandboxes.")
+	flags.StringVar(&gcNamespaces, "namespace", gcNamespaces, "Comma separated namespaces the gc command is restricted to.")
+	flags.StringVar(&pruneFromPlan, "prune-from-plan", pruneFromPlan, "Remove the containers and sandboxes of a plan printed by the gc command with -output json, after confirmation.")
+	flags.BoolVar(&assumeYes, "yes", assumeYes, "Do not ask for the confirmation of -prune.")
+	flags.IntVar(&gcStalledContainers, "gc-stalled-containers", gcStalledContainers, "Consider the garbage collection of the kubelet stalled from this many collectable containers.")
+	flags.BoolVar(&checkRuntimeConfig, "check-runtime-config", checkRuntimeConfig, "Pass the pod CIDR of the node to the runtime with UpdateRuntimeConfig after the relist and time the call.")
+	flags.StringVar(&podCIDR, "pod-cidr", podCIDR, "The pod CIDR for -check-runtime-config, read from -kubelet-config if empty.")
+	flags.StringVar(&kubeletConfig, "kubelet-config", kubeletConfig, "The kubelet configuration file holding the podCIDR for -check-runtime-config.")
+	flags.IntVar(&imagesTop, "images-top", imagesTop, "The number of largest images reported by the images command.")
+	flags.DurationVar(&slowImageStatus, "slow-image-status", slowImageStatus, "Report images of running containers whose verbose ImageStatus takes longer than this in the images command.")
+	flags.IntVar(&periodicitySamples, "periodicity-samples", periodicitySamples, "The number of relist durations of -watch analyzed for periodic spikes, 32 bytes each with the time of their relist.")
+	flags.IntVar(&maxRecentEvents, "event-buffer", maxRecentEvents, "The number of recent PLEG events kept for top and /events, about 200 bytes each.")
+	flags.IntVar(&heatmapSamples, "latency-buffer", heatmapSamples, "The number of recent latencies kept per CRI method for the heatmaps, 32 bytes each. A relist calls about 10 methods.")
+	flags.Float64Var(&periodicityMinCorrelation, "periodicity-min-correlation", periodicityMinCorrelation, "Report a period of the relist spikes from this autocorrelation.")
+	flags.BoolVar(&correlateJournal, "correlate-journal", correlateJournal, "Look for containerd garbage collection and compaction in journald when a relist breaches -relist-threshold.")
+	flags.StringVar(&nodeLabels, "node-labels", nodeLabels, "Comma separated key=value labels of the node added to JSON reports, e.g. the instance type.")
+	flags.StringVar(&listenAddress, "listen", listenAddress, "Serve /healthz, /heatmap and /events on this address with -watch, e.g. :9090. The events command reads /events from it. The address of the sink-test command, :8080 by default.")
+	flags.DurationVar(&relistHardThreshold, "relist-hard-threshold", relistHardThreshold, "/healthz answers 503 from this relist duration or on relist errors, and 200 but degraded over -relist-threshold.")
+	flags.Int64Var(&maxRSS, "max-rss", maxRSS, "Soft limit of the rss of -watch in bytes, over it verbose statuses and -inspect-namespaces are disabled then the interval is doubled. Both are restored step by step after 3 relists under 80% of the limit.")
+	flags.Float64Var(&maxCPU, "max-cpu", maxCPU, "Soft limit of the cpu usage of -watch in percent of one cpu, over it verbose statuses and -inspect-namespaces are disabled then the interval is doubled. Both are restored step by step after 3 relists under 80% of the limit.")
+	flags.BoolVar(&adaptiveInterval, "adaptive-interval", adaptiveInterval, "Scale the interval of -watch with the number of containers, adding -interval-per-100-containers per 100 containers.")
+	flags.DurationVar(&intervalPer100Containers, "interval-per-100-containers", intervalPer100Containers, "The interval added per 100 containers with -adaptive-interval.")
+	flags.BoolVar(&watchSocket, "watch-socket", watchSocket, "Reconnect when the runtime socket is replaced during -watch, e.g. by a runtime restart, and report the time of the restart.")
+	flags.DurationVar(&startJitter, "start-jitter", startJitter, "Delay the first relist of -watch by a random duration up to this long.")
+	flags.Float64Var(&intervalJitter, "interval-jitter", intervalJitter, "Add a random duration up to this fraction of the interval to every period of -watch, e.g. 0.1.")
+	flags.IntVar(&niceness, "nice", niceness, "Set the nice value of oncepleg, e.g. 19, Linux only.")
+	flags.StringVar(&ioPriority, "io-priority", ioPriority, "Set the IO priority of oncepleg, idle or best-effort:<0-7>, Linux only.")
+	flags.StringVar(&selfCgroup, "self-cgroup", selfCgroup, "Move oncepleg into this cgroup, created under the cpu hierarchy if missing, Linux only.")
+	flags.IntVar(&selfCPUQuota, "self-cpu-quota", selfCPUQuota, "The cpu limit of -self-cgroup in percent of one cpu, zero for unlimited.")
+	flags.BoolVar(&readOnly, "read-only", readOnly, "Reject every CRI call which may modify the node. The smoke command, -prune and -check-runtime-config need -read-only=false.")
+	flags.StringVar(&auditLog, "audit-log", auditLog, "Append every CRI call, with its targets and the command making it, to this file as JSON lines.")
+	flags.IntVar(&runAsGroup, "run-as-group", runAsGroup, "When started as root, switch to this gid after connecting to the runtime. The group needs access to the runtime socket.")
+	flags.IntVar(&runAsUser, "run-as-user", runAsUser, "When started as root, switch to this uid after connecting to the runtime, and to its primary group without -run-as-group. The output files, termination log and boot marker are opened before. An existing directory of -ready-file must be writable by the user.")
+	flags.Parse(args)
+	if quiet {
+		noColor, progress = true, false
+	}
+	if command != "top" {
+		setupColor(flags)
+	}
+	setupProgress(command)
+	setupQuiet(flags.Set)
+	if err := sortPods(nil, sortBy); err != nil {
+		klog.Fatal(err)
+	}
+	if durationUnit != "ms" && durationUnit != "s" {
+		klog.Fatalf("Unknown duration unit %q", durationUnit)
+	}
+	if maxRecentEvents < 0 || heatmapSamples < 1 || baselineMaxSamples < 0 {
+		klog.Fatal("-event-buffer and -baseline-max-samples can not be negative, -latency-buffer must be at least 1")
+	}
 
 	defer klog.Flush()
 
+	if printSchema {
+		if err := writeSchema(os.Stdout); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	// selfcheck, sink-test and plugins must work without access to the runtime.
+	if command == "selfcheck" {
+		selfcheck()
+		os.Exit(0)
+	}
+	if command == "sink-test" {
+		klog.Fatal(sinkTest(listenAddress))
+	}
+	if command == "events" {
+		if err := eventsCommand(listenAddress); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	}
+	if command == "plugins" {
+		if err := listPlugins(); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if dryRun {
+		if command != "" {
+			klog.Fatal("-dry-run only applies to the relist")
+		}
+		if err := writeRelistPlan(os.Stdout); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
+	if oncePerBoot {
+		if command != "" || watchInterval > 0 {
+			klog.Fatal("-once-per-boot only applies to a single relist")
+		}
+		ran, err := ranThisBoot()
+		if err != nil {
+			klog.Fatal(err)
+		}
+		if ran {
+			klog.Infof("Already relisted since the node booted, see %s", bootMarker)
+			os.Exit(0)
+		}
+	}
+
+	if err := setupSelfLimits(); err != nil {
+		klog.Fatal(err)
+	}
+
 	runtimeService, err := newRuntimeServiceClient(remoteRuntimeEndpoint, runtimeRequestTimeout)
 	if err != nil {
 		klog.Fatal(err)
 	}
+	if err := setupAudit(command, runtimeService.Clock); err != nil {
+		klog.Fatal(err)
+	}
+	startSelfStats(runtimeService.Clock)
+	if err := loadBaselines(); err != nil {
+		klog.Fatal(err)
+	}
+	if err := setupNotifiers(); err != nil {
+		klog.Fatal(err)
+	}
+	if err := openBeforeDrop(command); err != nil {
+		klog.Fatal(err)
+	}
+	if err := dropPrivileges(); err != nil {
+		klog.Fatal(err)
+	}
+
+	switch command {
+	case "":
+	case "top":
+		flags.Set("logtostderr", "false")
+		flags.Set("stderrthreshold", "FATAL")
+		interval := watchInterval
+		if interval <= 0 {
+			interval = topInterval
+		}
+		top(runtimeService, interval)
+	case "smoke":
+		if err := smoke(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "gc":
+		if err := gc(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "images":
+		if err := imagesCommand(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "gate":
+		if err := gate(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "shell":
+		run := runShell
+		if shellScript != "" {
+			run = runScript
+		}
+		if err := run(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "find":
+		if err := find(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "whois":
+		if err := whois(runtimeService, flags.Arg(0)); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "filter-efficacy":
+		if err := filterEfficacy(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	case "conformance":
+		if err := conformance(runtimeService); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	default:
+		klog.Fatalf("Unknown command %q", command)
+	}
 
-	pods, err := runtimeService.getPods()
+	if watchInterval > 0 {
+		watch(runtimeService)
+	}
+	err = run(runtimeService)
+	// Let the breach hook capture its data before exiting.
+	breachHooks.Wait()
+	if err := writeTerminationMessage(runtimeService.Clock.Now(), err); err != nil {
+		klog.Errorf("Write termination message to %s failed: %v", terminationLog, err)
+	}
+	if e, ok := err.(*violationError); ok {
+		klog.Error(e)
+		klog.Flush()
+		os.Exit(strictExitCode)
+	}
 	if err != nil {
 		klog.Fatal(err)
 	}
-
-	for _, pod := range pods {
-		err = runtimeService.getPodStatus(pod.ID, pod.Name, pod.Namespace)
-		if err != nil {
+	if oncePerBoot {
+		if err := writeBootMarker(); err != nil {
 			klog.Fatal(err)
 		}
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"time"
+)
+
+// containerMetadata is the part of a container which never changes, parsed
+// once and cached by container ID across the relists of a watch. Only the
+// state is taken from every list.
+type containerMetadata struct {
+	id        string
+	name      string
+	sandboxID string
+	attempt   uint32
+	image     string
+	imageRef  string
+	createdAt time.Time
+	labels    *labeledContainerInfo
+	// missingLabels are the kubelet labels the container does not have.
+	missingLabels []string
+}
+
+func newContainerMetadata(c *runtimeapi.Container) *containerMetadata {
+	return &containerMetadata{
+		id:        c.Id,
+		name:      c.GetMetadata().GetName(),
+		sandboxID: c.PodSandboxId,
+		attempt:   c.GetMetadata().GetAttempt(),
+		image:     c.GetImage().GetImage(),
+		imageRef:  c.ImageRef,
+		createdAt: unixNano(c.CreatedAt),
+		labels:    getContainerInfoFromLabels(c.Labels),
+
+		missingLabels: missingContainerLabels(c.Labels),
+	}
+}
+
+// result returns the container as listed, in state.
+func (m *containerMetadata) result(state runtimeapi.ContainerState) *containerResult {
+	return &containerResult{
+		ID:        m.id,
+		Name:      m.name,
+		State:     state.String(),
+		Image:     m.image,
+		ImageRef:  m.imageRef,
+		SandboxID: m.sandboxID,
+		Attempt:   m.attempt,
+		CreatedAt: m.createdAt,
+	}
+}
+
+// cachedMetadata returns the metadata of a listed container from the cache of
+// the previous relist, or parses it, and keeps it in next. The containers gone
+// since the previous relist are dropped with it. The cache of a relist is not
+// written once it is complete, so abandoned status calls can still read it.
+func (rs *runtimeService) cachedMetadata(c *runtimeapi.Container, next map[string]*containerMetadata) *containerMetadata {
+	m, ok := rs.metadata[c.Id]
+	if !ok {
+		m = newContainerMetadata(c)
+	}
+	next[c.Id] = m
+	return m
+}
+
+// newContainerResult returns a listed container, from the cache if it has it.
+func (rs *runtimeService) newContainerResult(c *runtimeapi.Container) *containerResult {
+	if m, ok := rs.metadata[c.Id]; ok {
+		return m.result(c.State)
+	}
+	return newContainerResult(c)
+}
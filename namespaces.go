@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/coderwangke/oncepleg/internal/platform"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/klog"
+	"sort"
+)
+
+// inspectNamespaces verifies the PID namespace of every ready sandbox.
+var inspectNamespaces = false
+
+// sandboxInfo is the subset of the verbose PodSandboxStatus info that
+// containerd and cri-o report under the "info" key.
+type sandboxInfo struct {
+	Pid    int `json:"pid"`
+	Config *struct {
+		Linux *struct {
+			SecurityContext *struct {
+				NamespaceOptions *struct {
+					// Pid is omitted for the default, POD.
+					Pid runtimeapi.NamespaceMode `json:"pid"`
+				} `json:"namespace_options"`
+			} `json:"security_context"`
+		} `json:"linux"`
+	} `json:"config"`
+	RuntimeSpec *struct {
+		Linux *struct {
+			Namespaces []struct {
+				Type string `json:"type"`
+				Path string `json:"path"`
+			} `json:"namespaces"`
+		} `json:"linux"`
+	} `json:"runtimeSpec"`
+}
+
+// pidMode is the PID namespace mode the kubelet asked for, false if the
+// runtime does not report the config.
+func (info *sandboxInfo) pidMode() (runtimeapi.NamespaceMode, bool) {
+	if info.Config == nil || info.Config.Linux == nil {
+		return 0, false
+	}
+	sc := info.Config.Linux.SecurityContext
+	if sc == nil || sc.NamespaceOptions == nil {
+		return runtimeapi.NamespaceMode_POD, true
+	}
+	return sc.NamespaceOptions.Pid, true
+}
+
+// specPIDNamespace returns whether the OCI runtime spec creates or joins a PID
+// namespace, and the path of a joined one.
+func (info *sandboxInfo) specPIDNamespace() (found bool, path string, known bool) {
+	if info.RuntimeSpec == nil || info.RuntimeSpec.Linux == nil {
+		return false, "", false
+	}
+	for _, ns := range info.RuntimeSpec.Linux.Namespaces {
+		if ns.Type == "pid" {
+			return true, ns.Path, true
+		}
+	}
+	return false, "", true
+}
+
+// checkSandboxNamespaces compares the PID namespace mode the kubelet asked for
+// each ready sandbox of the last relist with the mode of its status, its
+// runtime spec and, where /proc tells, the namespace the sandbox process is
+// actually in. A sandbox in the wrong namespace confuses the status of its
+// containers.
+func (rs *runtimeService) checkSandboxNamespaces() {
+	hostNS, err := processes.Namespace(1, "pid")
+	if err != nil && err != platform.ErrUnsupported {
+		klog.V(2).Infof("Host PID namespace can not be read: %v", err)
+	}
+	var ids []string
+	for id, s := range rs.sandboxes {
+		if s.State == runtimeapi.PodSandboxState_SANDBOX_READY.String() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	inconsistent := 0
+	for _, id := range ids {
+		status, info, err := rs.getSandboxInfo(id)
+		if err != nil {
+			klog.Errorf("Verbose PodSandboxStatus of sandbox %s failed: %v", id, err)
+			continue
+		}
+		if info == nil {
+			klog.V(2).Infof("Runtime does not report verbose info for sandbox %s", id)
+			continue
+		}
+		// The mode the runtime reports in the status must be the one asked for.
+		reported := status.GetLinux().GetNamespaces().GetOptions()
+		mode, ok := info.pidMode()
+		switch {
+		case !ok && reported == nil:
+			klog.V(4).Infof("Runtime reports neither the config nor the namespaces of sandbox %s", id)
+			continue
+		case !ok:
+			mode = reported.Pid
+		case reported != nil && reported.Pid != mode:
+			inconsistent++
+			klog.Warningf("Sandbox %s asks for a %s PID namespace but its status reports %s", id, mode, reported.Pid)
+		}
+		hostPID := mode == runtimeapi.NamespaceMode_NODE
+
+		if found, path, known := info.specPIDNamespace(); known {
+			switch {
+			case hostPID && found:
+				inconsistent++
+				klog.Warningf("Sandbox %s asks for the host PID namespace but its runtime spec sets up a PID namespace", id)
+			case !hostPID && !found:
+				inconsistent++
+				klog.Warningf("Sandbox %s asks for a %s PID namespace but its runtime spec runs it in the host PID namespace", id, mode)
+			case !hostPID && path != "":
+				inconsistent++
+				klog.Warningf("Sandbox %s asks for a %s PID namespace but its runtime spec joins %s", id, mode, path)
+			}
+		}
+
+		if info.Pid <= 0 || hostNS == "" {
+			continue
+		}
+		ns, err := processes.Namespace(info.Pid, "pid")
+		if err == platform.ErrUnsupported {
+			continue
+		}
+		if err != nil {
+			klog.V(2).Infof("PID namespace of sandbox %s process %d can not be read: %v", id, info.Pid, err)
+			continue
+		}
+		if hostPID && ns != hostNS {
+			inconsistent++
+			klog.Warningf("Sandbox %s asks for the host PID namespace but its process %d is in %s", id, info.Pid, ns)
+		} else if !hostPID && ns == hostNS {
+			inconsistent++
+			klog.Warningf("Sandbox %s asks for a %s PID namespace but its process %d is in the host PID namespace", id, mode, info.Pid)
+		}
+	}
+	klog.V(2).Infof("Checked the PID namespaces of %d sandboxes, %d inconsistent", len(ids), inconsistent)
+}
+
+// getSandboxInfo gets the status of a sandbox with its verbose info, which is
+// nil if the runtime does not report any (e.g. dockershim).
+func (rs *runtimeService) getSandboxInfo(id string) (*runtimeapi.PodSandboxStatus, *sandboxInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	resp, err := rs.Client.PodSandboxStatus(ctx, &runtimeapi.PodSandboxStatusRequest{PodSandboxId: id, Verbose: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, found := resp.Info["info"]
+	if !found {
+		return resp.Status, nil, nil
+	}
+	info := &sandboxInfo{}
+	if err := json.Unmarshal([]byte(raw), info); err != nil {
+		return nil, nil, err
+	}
+	return resp.Status, info, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io/ioutil"
+	"k8s.io/klog"
+	"path/filepath"
+	"strings"
+)
+
+// nodeLabels are comma separated key=value labels of the node added to reports,
+// e.g. the instance type, so results can be grouped by them.
+var nodeLabels = ""
+
+// nodeInfo describes the node and its runtime, so results of many nodes can be
+// compared by kernel or runtime version.
+type nodeInfo struct {
+	Labels            map[string]string `json:"labels,omitempty"`
+	Kernel            string            `json:"kernel,omitempty"`
+	RuntimeName       string            `json:"runtimeName,omitempty"`
+	RuntimeVersion    string            `json:"runtimeVersion,omitempty"`
+	RuntimeAPIVersion string            `json:"runtimeApiVersion,omitempty"`
+}
+
+// parseLabels parses comma separated key=value pairs.
+func parseLabels(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 {
+			labels[kv[0]] = kv[1]
+		} else {
+			labels[kv[0]] = ""
+		}
+	}
+	return labels
+}
+
+// getNodeInfo reads the node info once, the runtime version is left empty if
+// the Version call fails.
+func (rs *runtimeService) getNodeInfo() *nodeInfo {
+	if rs.node != nil {
+		return rs.node
+	}
+	info := &nodeInfo{Labels: parseLabels(nodeLabels)}
+	if data, err := ioutil.ReadFile(filepath.Join(procRoot, "sys/kernel/osrelease")); err == nil {
+		info.Kernel = strings.TrimSpace(string(data))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	resp, err := rs.Client.Version(ctx, &runtimeapi.VersionRequest{})
+	if err != nil {
+		klog.Warningf("Get runtime version failed: %v", err)
+		return info
+	}
+	info.RuntimeName, info.RuntimeVersion, info.RuntimeAPIVersion = resp.RuntimeName, resp.RuntimeVersion, resp.RuntimeApiVersion
+	rs.node = info
+	return info
+}
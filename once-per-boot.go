@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// oncePerBoot relists only if no relist succeeded since the node booted,
+	// as recorded in bootMarker.
+	oncePerBoot = false
+	bootMarker  = "/var/lib/oncepleg/boot-id"
+	// bootMarkerFile is bootMarker opened before dropping privileges.
+	bootMarkerFile *os.File
+)
+
+func readBootID() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, "sys/kernel/random/boot_id"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ranThisBoot reports whether bootMarker holds the ID of the current boot.
+func ranThisBoot() (bool, error) {
+	bootID, err := readBootID()
+	if err != nil {
+		return false, err
+	}
+	data, err := ioutil.ReadFile(bootMarker)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(data)) == bootID, nil
+}
+
+// writeBootMarker records that a relist succeeded during the current boot.
+func writeBootMarker() error {
+	bootID, err := readBootID()
+	if err != nil {
+		return err
+	}
+	if bootMarkerFile != nil {
+		return rewriteFile(bootMarkerFile, []byte(bootID+"\n"))
+	}
+	if err := os.MkdirAll(filepath.Dir(bootMarker), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(bootMarker, []byte(bootID+"\n"), 0644)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+var (
+	pagerDutyURL        = "https://events.pagerduty.com/v2/enqueue"
+	pagerDutyRoutingKey = ""
+)
+
+// pagerDutyEvent is an event of the PagerDuty Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string      `json:"summary"`
+	Source        string      `json:"source"`
+	Severity      string      `json:"severity"`
+	Timestamp     string      `json:"timestamp"`
+	Component     string      `json:"component"`
+	CustomDetails interface{} `json:"custom_details"`
+}
+
+type pagerDutyNotifier struct {
+	client *http.Client
+}
+
+func newPagerDutyNotifier() *pagerDutyNotifier {
+	return &pagerDutyNotifier{
+		client: &http.Client{Timeout: notifyTimeout},
+	}
+}
+
+// notify triggers an incident keyed by node and check when the alert fires
+// and resolves it when the alert is resolved.
+func (n *pagerDutyNotifier) notify(a *alert) error {
+	key := fmt.Sprintf("oncepleg/%s/%s", a.Node, a.Check)
+	if a.State == alertResolved {
+		return n.send(&pagerDutyEvent{
+			RoutingKey:  pagerDutyRoutingKey,
+			EventAction: "resolve",
+			DedupKey:    key,
+		})
+	}
+
+	severity := a.Severity
+	if a.Error != "" {
+		severity = "critical"
+	}
+	return n.send(&pagerDutyEvent{
+		RoutingKey:  pagerDutyRoutingKey,
+		EventAction: "trigger",
+		DedupKey:    key,
+		Payload: &pagerDutyPayload{
+			Summary:       fmt.Sprintf("oncepleg on node %s: %s", a.Node, a.Summary()),
+			Source:        a.Node,
+			Severity:      severity,
+			Timestamp:     a.Time.Format(time.RFC3339),
+			Component:     "container-runtime",
+			CustomDetails: a,
+		},
+	})
+}
+
+func (n *pagerDutyNotifier) send(event *pagerDutyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(pagerDutyURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("pagerduty responded %s: %s", resp.Status, msg)
+	}
+	return nil
+}
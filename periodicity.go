@@ -0,0 +1,118 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"time"
+)
+
+var (
+	// periodicitySamples is the number of relist durations analyzed for periodic
+	// spikes, a duration and the time of its relist take 32 bytes.
+	periodicitySamples = 360
+	// periodicityMinCorrelation is the autocorrelation from which a period is reported.
+	periodicityMinCorrelation = 0.5
+)
+
+// relistSeries are the durations of the recent relists of the watch, and
+// relistTimes the times they started.
+var (
+	relistSeries []float64
+	relistTimes  []time.Time
+)
+
+// autocorrelation returns the autocorrelation of series at lag.
+func autocorrelation(series []float64, lag int) float64 {
+	mean := 0.0
+	for _, x := range series {
+		mean += x
+	}
+	mean /= float64(len(series))
+	variance, covariance := 0.0, 0.0
+	for i, x := range series {
+		variance += (x - mean) * (x - mean)
+		if i+lag < len(series) {
+			covariance += (x - mean) * (series[i+lag] - mean)
+		}
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
+
+// detectPeriod returns the lag at which the series repeats itself, or zero.
+// The shortest lag close to the best one is preferred, as multiples of the
+// period correlate too.
+func detectPeriod(series []float64) (int, float64) {
+	if !hasSpikes(series) {
+		return 0, 0
+	}
+	// A period must be seen at least three times.
+	maxLag := len(series) / 3
+	best, bestScore := 0, 0.0
+	scores := make([]float64, maxLag+1)
+	for lag := 2; lag <= maxLag; lag++ {
+		scores[lag] = autocorrelation(series, lag)
+		if scores[lag] > bestScore {
+			best, bestScore = lag, scores[lag]
+		}
+	}
+	if bestScore < periodicityMinCorrelation {
+		return 0, bestScore
+	}
+	for lag := 2; lag < best; lag++ {
+		if scores[lag] >= 0.9*bestScore {
+			return lag, scores[lag]
+		}
+	}
+	return best, bestScore
+}
+
+// hasSpikes returns whether some values exceed the mean by more than 10%,
+// so noise is not reported as periodic.
+func hasSpikes(series []float64) bool {
+	mean := 0.0
+	for _, x := range series {
+		mean += x
+	}
+	mean /= float64(len(series))
+	for _, x := range series {
+		if x > 1.1*mean {
+			return true
+		}
+	}
+	return false
+}
+
+// observeRelistPeriod records the duration of a relist of the watch started
+// at t and returns the period of the relist spikes, zero if there is none.
+func observeRelistPeriod(t time.Time, d time.Duration) time.Duration {
+	relistSeries = append(relistSeries, float64(d))
+	relistTimes = append(relistTimes, t)
+	if len(relistSeries) > periodicitySamples {
+		relistSeries = relistSeries[len(relistSeries)-periodicitySamples:]
+		relistTimes = relistTimes[len(relistTimes)-periodicitySamples:]
+	}
+	lag, score := detectPeriod(relistSeries)
+	if lag == 0 {
+		return 0
+	}
+	period := lagPeriod(relistTimes, lag)
+	klog.V(2).Infof("Relist spikes repeat every %v, autocorrelation %.2f", period, score)
+	return period
+}
+
+// lagPeriod returns the mean time between the relists lag apart, which the
+// adaptive interval and the jitter keep from being lag intervals.
+func lagPeriod(times []time.Time, lag int) time.Duration {
+	var total time.Duration
+	n := 0
+	for i := 0; i+lag < len(times); i++ {
+		total += times[i+lag].Sub(times[i])
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLagPeriod checks the period of the spikes comes from the times of the
+// relists, which the adaptive interval spaced unevenly.
+func TestLagPeriod(t *testing.T) {
+	clock := newFakeClock()
+	var times []time.Time
+	for i := 0; i < 12; i++ {
+		times = append(times, clock.Now())
+		// The interval doubled halfway.
+		if i < 6 {
+			clock.Step(10 * time.Second)
+		} else {
+			clock.Step(20 * time.Second)
+		}
+	}
+	// Lags of 3 relists span 30s, 40s, 50s or 60s.
+	want := (4*30*time.Second + 40*time.Second + 50*time.Second + 3*60*time.Second) / 9
+	if got := lagPeriod(times, 3); got != want {
+		t.Errorf("period %v, want %v", got, want)
+	}
+	if got := lagPeriod(times[:3], 3); got != 0 {
+		t.Errorf("period %v without relists 3 apart, want 0", got)
+	}
+}
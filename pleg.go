@@ -0,0 +1,107 @@
+package main
+
+import (
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/klog"
+	"sync"
+	"time"
+)
+
+// maxRecentEvents bounds the events kept for top and /events, an event takes
+// about 200 bytes.
+var maxRecentEvents = 100
+
+// plegEventType mirrors the event types generated by the kubelet PLEG.
+type plegEventType string
+
+const (
+	ContainerStarted plegEventType = "ContainerStarted"
+	ContainerDied    plegEventType = "ContainerDied"
+	ContainerRemoved plegEventType = "ContainerRemoved"
+	ContainerChanged plegEventType = "ContainerChanged"
+)
+
+type plegEvent struct {
+	Time        time.Time
+	Type        plegEventType
+	PodID       string
+	ContainerID string
+	// Relist is the number of the relist of the session which detected the event.
+	Relist int
+}
+
+// containerRecord is the state of a container seen by a relist.
+type containerRecord struct {
+	PodID string
+	State runtimeapi.ContainerState
+}
+
+var (
+	recentEventsLock sync.Mutex
+	recentEvents     []*plegEvent
+)
+
+// generateEvents compares the containers of two relists like the kubelet PLEG.
+func generateEvents(now time.Time, old, new map[string]containerRecord) []*plegEvent {
+	var events []*plegEvent
+	add := func(t plegEventType, podID, containerID string) {
+		events = append(events, &plegEvent{Time: now, Type: t, PodID: podID, ContainerID: containerID})
+	}
+
+	for id, n := range new {
+		o, found := old[id]
+		if found && o.State == n.State {
+			continue
+		}
+		switch n.State {
+		case runtimeapi.ContainerState_CONTAINER_RUNNING:
+			add(ContainerStarted, n.PodID, id)
+		case runtimeapi.ContainerState_CONTAINER_EXITED:
+			add(ContainerDied, n.PodID, id)
+		default:
+			add(ContainerChanged, n.PodID, id)
+		}
+	}
+	for id, o := range old {
+		if _, found := new[id]; found {
+			continue
+		}
+		if o.State != runtimeapi.ContainerState_CONTAINER_EXITED {
+			add(ContainerDied, o.PodID, id)
+		}
+		add(ContainerRemoved, o.PodID, id)
+	}
+	return events
+}
+
+// recordEvents logs the events and keeps the most recent ones.
+func recordEvents(events []*plegEvent) {
+	if klog.V(2) {
+		for _, e := range events {
+			klog.Infof("PLEG event: %s, Pod ID: %s, Container ID: %s", e.Type, e.PodID, e.ContainerID)
+		}
+	}
+
+	recentEventsLock.Lock()
+	defer recentEventsLock.Unlock()
+	recentEvents = append(recentEvents, events...)
+	// Shift the events in place rather than copying them to a new array on
+	// every relist once the buffer is full.
+	if n := len(recentEvents) - maxRecentEvents; n > 0 {
+		copy(recentEvents, recentEvents[n:])
+		for i := maxRecentEvents; i < len(recentEvents); i++ {
+			recentEvents[i] = nil
+		}
+		recentEvents = recentEvents[:maxRecentEvents]
+	}
+}
+
+// getRecentEvents returns the last n events, oldest first.
+func getRecentEvents(n int) []*plegEvent {
+	recentEventsLock.Lock()
+	defer recentEventsLock.Unlock()
+	if n > len(recentEvents) {
+		n = len(recentEvents)
+	}
+	return append([]*plegEvent(nil), recentEvents[len(recentEvents)-n:]...)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"k8s.io/klog"
+	"sort"
+)
+
+var (
+	kubeletPodsDir = "/var/lib/kubelet/pods"
+
+	// checkPodDirs enables the comparison of kubelet pod directories with the sandbox list.
+	checkPodDirs = false
+)
+
+// checkPodDirsConsistency compares the pod UIDs in the kubelet pods directory with
+// the sandboxes of the runtime and reports orphans in either direction.
+func (rs *runtimeService) checkPodDirsConsistency(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs[entry.Name()] = true
+		}
+	}
+
+	sandboxes, err := rs.getKubeletSandboxs("", true)
+	if err != nil {
+		return err
+	}
+	uids := make(map[string]string)
+	for _, s := range sandboxes {
+		if s.Metadata == nil {
+			continue
+		}
+		uids[s.Metadata.Uid] = s.Metadata.Namespace + "/" + s.Metadata.Name
+	}
+
+	var withoutSandbox, withoutDir []string
+	for uid := range dirs {
+		if _, found := uids[uid]; !found {
+			withoutSandbox = append(withoutSandbox, uid)
+		}
+	}
+	for uid, pod := range uids {
+		if !dirs[uid] {
+			withoutDir = append(withoutDir, uid+" ("+pod+")")
+		}
+	}
+	sort.Strings(withoutSandbox)
+	sort.Strings(withoutDir)
+
+	for _, uid := range withoutSandbox {
+		klog.Warningf("Pod directory %s/%s has no sandbox in the runtime", dir, uid)
+	}
+	for _, pod := range withoutDir {
+		klog.Warningf("Sandbox of pod %s has no pod directory in %s", pod, dir)
+	}
+	klog.V(2).Infof("Pod directories: %d, sandboxed pods: %d, directories without sandbox: %d, sandboxed pods without directory: %d",
+		len(dirs), len(uids), len(withoutSandbox), len(withoutDir))
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"k8s.io/klog"
+	"sync/atomic"
+	"time"
+)
+
+// podStatusTimeout is the budget of the status calls of one pod, zero waits
+// for them however long they take.
+var podStatusTimeout = time.Duration(0)
+
+// abandonedKey is the context key of the flag the watchdog sets when it
+// abandons the status calls of a pod.
+type abandonedKey struct{}
+
+// abandoned reports whether the call of ctx was abandoned by the watchdog.
+// Such a call belongs to no relist, it is kept out of the RPC stats.
+func abandoned(ctx context.Context) bool {
+	flag, _ := ctx.Value(abandonedKey{}).(*int32)
+	return flag != nil && atomic.LoadInt32(flag) == 1
+}
+
+// getPodStatusWithWatchdog runs the status calls of a pod in a watchdog
+// goroutine. A pod over podStatusTimeout is recorded as timed out and the
+// relist moves on to the next pod instead of blocking on it. The abandoned
+// calls are cancelled and not recorded in the RPC stats. The goroutine works
+// on a copy of rs with its own counters, merged into the relist only if the
+// pod completes. A timed out pod keeps its containers as listed.
+func (rs *runtimeService) getPodStatusWithWatchdog(pod *Pod) (*podResult, error) {
+	if podStatusTimeout <= 0 {
+		return rs.getPodStatus(pod.ID, pod.Name, pod.Namespace)
+	}
+	var flag int32
+	parent := rs.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(parent, abandonedKey{}, &flag))
+	defer cancel()
+	worker := *rs
+	worker.ctx = ctx
+	worker.inconsistencies, worker.violations, worker.clockSkew = 0, 0, 0
+
+	type outcome struct {
+		result *podResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := worker.getPodStatus(pod.ID, pod.Name, pod.Namespace)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		rs.inconsistencies += worker.inconsistencies
+		rs.violations += worker.violations
+		if worker.clockSkew > rs.clockSkew {
+			rs.clockSkew = worker.clockSkew
+		}
+		return o.result, o.err
+	case <-rs.Clock.After(podStatusTimeout):
+		atomic.StoreInt32(&flag, 1)
+		klog.Warningf("Status of pod %s/%s timed out after %v, abandoned", pod.Namespace, pod.Name, podStatusTimeout)
+		result := &podResult{
+			ID:         pod.ID,
+			Name:       pod.Name,
+			Namespace:  pod.Namespace,
+			Containers: pod.Containers,
+			Latency:    podStatusTimeout,
+			TimedOut:   true,
+		}
+		for _, c := range pod.Containers {
+			if result.CreatedAt.IsZero() || c.CreatedAt.Before(result.CreatedAt) {
+				result.CreatedAt = c.CreatedAt
+			}
+		}
+		return result, nil
+	}
+}
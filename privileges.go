@@ -0,0 +1,20 @@
+package main
+
+import "os"
+
+var (
+	// runAsGroup and runAsUser are the gid and uid oncepleg drops to after
+	// opening its resources when started as root, -1 keeps root. The group
+	// needs access to the runtime socket to reconnect.
+	runAsGroup = -1
+	runAsUser  = -1
+)
+
+// rewriteFile replaces the content of a file opened before dropping privileges.
+func rewriteFile(f *os.File, data []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(data, 0)
+	return err
+}
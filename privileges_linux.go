@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"k8s.io/klog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// dropsPrivileges reports whether dropPrivileges switches to another user or group.
+func dropsPrivileges() bool {
+	return os.Geteuid() == 0 && (runAsGroup >= 0 || runAsUser >= 0)
+}
+
+// dropIDs returns the uid and gid to switch to. Without -run-as-group the gid
+// is the primary group of -run-as-user, keeping gid 0 would keep access to
+// everything owned by root's group.
+func dropIDs() (uid, gid int, err error) {
+	uid, gid = runAsUser, runAsGroup
+	if uid < 0 {
+		uid = os.Geteuid()
+	}
+	if gid >= 0 {
+		return uid, gid, nil
+	}
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return 0, 0, fmt.Errorf("look up the primary group of uid %d, set -run-as-group: %v", uid, err)
+	}
+	gid, err = strconv.Atoi(u.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("primary group %q of uid %d: %v", u.Gid, uid, err)
+	}
+	return uid, gid, nil
+}
+
+// openBeforeDrop opens the files written after dropping privileges, which the
+// unprivileged user is usually not allowed to open: the output files, the
+// termination log and the boot marker. The directory of the ready file of the
+// gate command is created for the user if missing.
+func openBeforeDrop(command string) error {
+	if !dropsPrivileges() {
+		return nil
+	}
+	uid, gid, err := dropIDs()
+	if err != nil {
+		return err
+	}
+	for _, s := range outputSinks {
+		// Rotating renames, creates and removes files in the directory of the
+		// output, only the file itself can be opened ahead.
+		if outputMaxBytes > 0 && s.path != "" && s.format != "loki" && s.format != "plugin" {
+			return fmt.Errorf("-output-max-bytes can not rotate %s after dropping privileges, the user may not write to its directory", s.path)
+		}
+	}
+	for _, s := range outputSinks {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if terminationLog != "" {
+		// Only written if it exists, like kubelet creates it.
+		if f, err := os.OpenFile(terminationLog, os.O_WRONLY, 0); err == nil {
+			terminationFile = f
+		}
+	}
+	if oncePerBoot {
+		if err := os.MkdirAll(filepath.Dir(bootMarker), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(bootMarker, os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		bootMarkerFile = f
+	}
+	if command == "gate" {
+		// The ready file can not be opened ahead, its existence lets pods through.
+		if err := os.Remove(readyFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		dir := filepath.Dir(readyFile)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			if err := os.Chown(dir, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropPrivileges switches to runAsGroup and runAsUser when running as root,
+// clearing the supplementary groups of root.
+func dropPrivileges() error {
+	if !dropsPrivileges() {
+		return nil
+	}
+	uid, gid, err := dropIDs()
+	if err != nil {
+		return err
+	}
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("clear supplementary groups: %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("set gid to %d: %v", gid, err)
+	}
+	if runAsUser >= 0 {
+		if err := syscall.Setuid(uid); err != nil {
+			return fmt.Errorf("set uid to %d: %v", uid, err)
+		}
+	}
+	klog.V(2).Infof("Dropped privileges to uid %d gid %d", os.Geteuid(), os.Getegid())
+	return nil
+}
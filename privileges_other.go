@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "fmt"
+
+// openBeforeDrop has nothing to open, privileges are never dropped.
+func openBeforeDrop(command string) error {
+	return nil
+}
+
+// dropPrivileges is only supported on Linux.
+func dropPrivileges() error {
+	if runAsGroup >= 0 || runAsUser >= 0 {
+		return fmt.Errorf("-run-as-group and -run-as-user are only supported on Linux")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/coderwangke/oncepleg/internal/platform"
+	"k8s.io/klog"
+)
+
+var (
+	procRoot  = "/proc"
+	processes = platform.NewProcInspector(procRoot)
+
+	// inspectProcesses enables the /proc inspection of container processes.
+	inspectProcesses = false
+)
+
+// containerInfo is the subset of the verbose ContainerStatus info that
+// containerd and cri-o report under the "info" key.
+type containerInfo struct {
+	Pid         int `json:"pid"`
+	RuntimeSpec *struct {
+		Linux *struct {
+			CgroupsPath string `json:"cgroupsPath"`
+		} `json:"linux"`
+	} `json:"runtimeSpec"`
+}
+
+// verboseStatus reports whether status calls must request verbose info.
+func verboseStatus() bool {
+	return inspectProcesses || inspectCgroups
+}
+
+// getContainerInfo parses the verbose info of a ContainerStatus response.
+// It returns nil if the runtime did not report any (e.g. dockershim).
+func getContainerInfo(info map[string]string) (*containerInfo, error) {
+	raw, found := info["info"]
+	if !found {
+		return nil, nil
+	}
+	ci := &containerInfo{}
+	if err := json.Unmarshal([]byte(raw), ci); err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+// checkContainerProcess flags container processes that are zombies or stuck in
+// uninterruptible sleep, a classic cause of ContainerStatus hangs.
+func checkContainerProcess(containerID string, pid int) {
+	if pid <= 0 {
+		klog.V(4).Infof("Container %s has no running process", containerID)
+		return
+	}
+	proc, err := processes.Process(pid)
+	if err == platform.ErrUnsupported {
+		klog.V(4).Infof("Container %s process %d can not be inspected: %v", containerID, pid, err)
+		return
+	}
+	if err != nil {
+		klog.Warningf("Container %s process %d can not be inspected: %v", containerID, pid, err)
+		return
+	}
+	if proc.State == "Z" {
+		klog.Warningf("Container %s process %d (%s) is a zombie", containerID, pid, proc.Comm)
+		return
+	}
+	for _, t := range proc.Threads {
+		if t.State == "D" {
+			klog.Warningf("Container %s process %d thread %d (%s) is in uninterruptible sleep, wchan: %s", containerID, pid, t.Tid, t.Comm, t.Wchan)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"k8s.io/klog"
+	"os"
+	"time"
+)
+
+var (
+	// progress shows the progress of the status phase on stderr when it is a terminal.
+	progress = true
+
+	progressEnabled = false
+)
+
+func setupProgress(command string) {
+	progressEnabled = progress && command != "top" && isTerminal(os.Stderr)
+}
+
+// progressLine draws "n of N pods (elapsed, ETA)" at the bottom of the log, so
+// operators can tell a slow runtime from a hung tool.
+type progressLine struct {
+	total int
+	clock clock
+	since time.Time
+	// logs is whether the status calls log, then the line is only drawn
+	// between pods so it is never mixed with a log line.
+	logs bool
+}
+
+func newProgressLine(c clock, total int) *progressLine {
+	return &progressLine{total: total, clock: c, since: c.Now(), logs: bool(klog.V(2))}
+}
+
+// start is called before the status calls of pod i.
+func (p *progressLine) start(i int) {
+	if p.logs {
+		p.clear()
+		return
+	}
+	p.update(i)
+}
+
+// finish is called after the status calls of pod i.
+func (p *progressLine) finish(i int) {
+	if p.logs {
+		p.update(i + 1)
+		return
+	}
+	p.clear()
+}
+
+// clear removes the line before anything else is logged.
+func (p *progressLine) clear() {
+	if progressEnabled {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+func (p *progressLine) update(done int) {
+	if !progressEnabled || done >= p.total {
+		return
+	}
+	elapsed := p.clock.Since(p.since)
+	eta := "unknown"
+	if done > 0 {
+		eta = (elapsed / time.Duration(done) * time.Duration(p.total-done)).Round(time.Millisecond).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%d of %d pods (elapsed %v, ETA %s)", done, p.total, elapsed.Round(time.Millisecond), eta)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"strings"
+)
+
+// readOnly rejects every CRI call which may modify the node, so oncepleg
+// structurally can not touch workloads unless -read-only=false is set.
+var readOnly = true
+
+// readOnlyMethods are the CRI methods allowed in read-only mode.
+var readOnlyMethods = map[string]bool{
+	"Version":            true,
+	"Status":             true,
+	"ListPodSandbox":     true,
+	"PodSandboxStatus":   true,
+	"ListContainers":     true,
+	"ContainerStatus":    true,
+	"ListContainerStats": true,
+	"ContainerStats":     true,
+	"ListImages":         true,
+	"ImageStatus":        true,
+	"ImageFsInfo":        true,
+}
+
+// enforceReadOnly is a grpc.UnaryClientInterceptor rejecting the methods
+// which are not allowed in read-only mode.
+func enforceReadOnly(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	name := method[strings.LastIndexByte(method, '/')+1:]
+	if readOnly && !readOnlyMethods[name] {
+		err := grpcstatus.Errorf(codes.PermissionDenied, "%s modifies the node, set -read-only=false to allow it", name)
+		if audit != nil {
+			audit.record(audit.clock.Now(), method, req, err)
+		}
+		return err
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// redact hashes workload identities in the report, so it can be shared with
+// runtime vendors.
+var redact = false
+
+// redactKey is generated per run, so the same name always hashes the same in
+// a report but can not be matched against a dictionary of known names.
+var redactKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// redacted returns a stable hash of s prefixed by kind (e.g. "pod", "ns") if
+// redaction is enabled.
+func redacted(kind, s string) string {
+	if !redact || s == "" {
+		return s
+	}
+	mac := hmac.New(sha256.New, redactKey)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(s))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"sort"
+	"time"
+)
+
+// watchInterval is the period between relists, zero relists only once.
+var watchInterval time.Duration
+
+// relistResult is the outcome of one relist.
+type relistResult struct {
+	Time     time.Time
+	Duration time.Duration
+	// ListDuration is the time spent listing sandboxes and containers, and
+	// StatusDuration the time spent on the status calls of every pod.
+	ListDuration   time.Duration
+	StatusDuration time.Duration
+	Pods           []*podResult
+	Containers     int
+	Events         []*plegEvent
+	// Inconsistencies counts the calls of the relist disagreeing with each other.
+	Inconsistencies int
+	// Collisions counts the ready sandboxes claiming the same pod.
+	Collisions int
+	// EmptySandboxes are the sandboxes without containers.
+	EmptySandboxes []string
+	// OrphanContainers are the containers without a pod UID label.
+	OrphanContainers []string
+	// Violations counts the conformance violations in strict mode.
+	Violations int
+	// ClockSkew is how far the clock of the runtime is ahead of the host
+	// clock, judged by container timestamps in the future.
+	ClockSkew time.Duration
+	// ListSaved is how much shorter the list phase was with -parallel-list
+	// than its two calls in sequence.
+	ListSaved time.Duration
+	// Hedges are the counts of the hedged status calls, nil if none.
+	Hedges *hedgeCounts
+	// Interval is the period of the watch before this relist, zero if not watching.
+	Interval time.Duration
+	// Period is the period of the relist spikes of the watch, if any.
+	Period time.Duration
+	// RuntimeRestarts counts the runtime restarts during the session, the last
+	// at RuntimeRestartedAt, which explain latency discontinuities.
+	RuntimeRestarts    int
+	RuntimeRestartedAt time.Time
+	// Causes are the likely causes of a relist breaching the threshold.
+	Causes []string
+	// RPCs are the latencies of the CRI calls made by the relist.
+	RPCs map[string]*methodStats
+	Err  error
+
+	Host          *hostSnapshot
+	RuntimeDaemon *runtimeDaemonHealth
+	NodeInfo      *nodeInfo
+	Self          *selfStats
+}
+
+// share is the percentage of the relist spent on d.
+func (r *relistResult) share(d time.Duration) float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(d) / float64(r.Duration) * 100
+}
+
+// relist lists all pods and gets the status of each of them like the PLEG does,
+// and generates the PLEG events since the previous relist.
+func relist(rs *runtimeService) *relistResult {
+	result := &relistResult{Time: rs.Clock.Now()}
+	relistCount++
+	rs.Stats.reset()
+	rs.inconsistencies, rs.collisions = 0, 0
+	rs.emptySandboxes, rs.orphanContainers = nil, nil
+	rs.violations = 0
+	rs.clockSkew = 0
+	rs.listSaved = 0
+	rs.sandboxes = nil
+	resetHedges()
+	old := rs.containers
+
+	pods, err := rs.getPods()
+	result.ListDuration = rs.Clock.Since(result.Time)
+	result.ListSaved = rs.listSaved
+	if err == nil {
+		// The pods come from a map, order them so runs are comparable.
+		sort.Slice(pods, func(i, j int) bool {
+			if pods[i].Namespace != pods[j].Namespace {
+				return pods[i].Namespace < pods[j].Namespace
+			}
+			if pods[i].Name != pods[j].Name {
+				return pods[i].Name < pods[j].Name
+			}
+			return pods[i].ID < pods[j].ID
+		})
+		result.Containers = len(rs.containers)
+		result.Pods = make([]*podResult, 0, len(pods))
+		progress := newProgressLine(rs.Clock, len(pods))
+		for i, pod := range pods {
+			progress.start(i)
+			var status *podResult
+			status, err = rs.getPodStatusWithWatchdog(pod)
+			progress.finish(i)
+			if err != nil {
+				break
+			}
+			result.Pods = append(result.Pods, status)
+		}
+		progress.clear()
+	}
+	result.Duration = rs.Clock.Since(result.Time)
+	result.StatusDuration = result.Duration - result.ListDuration
+	result.RPCs = rs.Stats.reset()
+	result.Inconsistencies = rs.inconsistencies
+	result.Collisions = rs.collisions
+	result.EmptySandboxes, result.OrphanContainers = rs.emptySandboxes, rs.orphanContainers
+	result.Violations = rs.violations
+	result.ClockSkew = rs.clockSkew
+	result.Hedges = resetHedges()
+	result.Err = err
+	if err != nil {
+		return result
+	}
+	if klog.V(2) {
+		klog.Infof("Relist %d pods, List: %v, Status: %v, Threshold: %v\n", len(result.Pods), result.ListDuration, result.StatusDuration, colorDuration(result.Duration, relistThreshold))
+	}
+
+	// The first relist has nothing to compare with.
+	if old != nil {
+		result.Events = generateEvents(rs.Clock.Now(), old, rs.containers)
+		rs.spareRecords = old
+		for _, e := range result.Events {
+			e.Relist = relistCount
+		}
+		recordEvents(result.Events)
+	}
+	return result
+}
+
+// run relists once, runs the enabled checks around it and notifies about a
+// breached relist threshold.
+func run(rs *runtimeService) error {
+	daemon := findRuntimeDaemon(remoteRuntimeEndpoint, rs.Clock)
+	if daemon == nil {
+		klog.V(2).Infof("Runtime daemon process of %s not found", remoteRuntimeEndpoint)
+	}
+	checkDaemonRestart(daemon)
+	cpu := readCPUTimes()
+	breaches = nil
+	result := relist(rs)
+	result.Interval = currentInterval
+	result.RuntimeRestarts, result.RuntimeRestartedAt = runtimeRestarts, runtimeRestartedAt
+	setLastResult(result)
+	notify(rs.Clock, newRelistAlert(rs.Clock.Now(), result.Duration, len(result.Pods), result.Err))
+	notifyMethods(rs.Clock, result.RPCs)
+	if err := saveBaselines(rs.Clock.Now()); err != nil {
+		klog.Errorf("Save baselines to %s failed: %v", baselineFile, err)
+	}
+	if result.Err != nil {
+		return result.Err
+	}
+
+	annotateCauses(result, daemon)
+	if watchInterval > 0 {
+		result.Period = observeRelistPeriod(result.Time, result.Duration)
+	}
+
+	result.NodeInfo = rs.getNodeInfo()
+	result.Host = takeHostSnapshot(cpu)
+	klog.V(2).Infof("Host %s", result.Host)
+	if daemon != nil {
+		health, err := daemon.health()
+		if err != nil {
+			klog.Warningf("Inspect runtime daemon failed: %v", err)
+		} else {
+			klog.V(2).Infof("Runtime daemon %s", health)
+			health.check()
+			result.RuntimeDaemon = health
+		}
+	}
+
+	result.Self = takeSelfStats(rs.Clock)
+	klog.V(2).Infof("Self %s", result.Self)
+
+	if err := sortPods(result.Pods, sortBy); err != nil {
+		return err
+	}
+	if err := writeResults(rs.Clock, result); err != nil {
+		return err
+	}
+
+	blocked := activeChecksBlocked(rs.Clock.Now(), result.Host.Load1)
+	if (diskCheck || checkRuntimeConfig) && blocked != "" {
+		klog.V(2).Infof("Skip the intrusive checks: %s", blocked)
+	}
+	if diskCheck && blocked == "" {
+		comm := ""
+		if daemon != nil {
+			comm = daemon.Comm
+		}
+		runDiskCheck(rs.Clock, comm)
+	}
+	if scanLogs {
+		runLogScan(rs.Clock)
+	}
+	if checkPodDirs {
+		if err := rs.checkPodDirsConsistency(kubeletPodsDir); err != nil {
+			klog.Errorf("Check pod directories failed: %v", err)
+		}
+	}
+
+	if inspectNamespaces {
+		rs.checkSandboxNamespaces()
+	}
+
+	if checkRuntimeConfig && blocked == "" {
+		if err := rs.checkUpdateRuntimeConfig(); err != nil {
+			klog.Errorf("Check UpdateRuntimeConfig failed: %v", err)
+		}
+	}
+
+	if result.Violations > 0 {
+		return &violationError{violations: result.Violations}
+	}
+	return nil
+}
+
+// watch relists every currentInterval until the process is stopped, failed
+// relists are reported but do not stop the watch. With -watch-socket it
+// reconnects when the runtime socket is replaced. SIGUSR2 writes the latency
+// heatmaps to stderr, which are also served with the health on -listen.
+func watch(rs *runtimeService) {
+	handleHeatmapSignal(rs.Stats)
+	startHTTPServer(rs)
+	if watchSocket {
+		if err := startSocketWatch(remoteRuntimeEndpoint); err != nil {
+			klog.Warningf("Watch runtime socket failed: %v", err)
+		}
+	}
+	sleepStartJitter()
+	currentInterval = relistInterval(0)
+	for {
+		start := rs.Clock.Now()
+		rs.checkSocketReplaced()
+		if err := run(rs); err != nil {
+			klog.Errorf("Relist failed: %v", err)
+		}
+		klog.Flush()
+		lastResultMu.Lock()
+		last := lastResult
+		lastResultMu.Unlock()
+		adaptToBudget(last.Self)
+		currentInterval = relistInterval(last.Containers)
+		rs.Clock.Sleep(currentInterval - rs.Clock.Since(start))
+	}
+}
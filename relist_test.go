@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"google.golang.org/grpc"
+	"testing"
+	"time"
+)
+
+// relistAllocsBudget bounds the allocations of a relist of benchPods pods
+// with benchContainers containers each, in the steady state of a watch.
+const (
+	benchPods          = 250
+	benchContainers    = 2
+	relistAllocsBudget = 55 * benchPods
+)
+
+// fakeRuntimeClient serves a fixed node from memory. The responses are built
+// ahead, so the allocations measured are the ones of the relist.
+type fakeRuntimeClient struct {
+	runtimeapi.RuntimeServiceClient
+	sandboxes       []*runtimeapi.PodSandbox
+	containers      []*runtimeapi.Container
+	podSandboxes    map[string][]*runtimeapi.PodSandbox
+	podContainers   map[string][]*runtimeapi.Container
+	sandboxStatus   map[string]*runtimeapi.PodSandboxStatusResponse
+	containerStatus map[string]*runtimeapi.ContainerStatusResponse
+	// sandboxLists and containerLists count the lists filtered by pod UID.
+	sandboxLists   map[string]int
+	containerLists map[string]int
+	// hang are the sandboxes whose status never answers.
+	hang map[string]bool
+	// removed are the containers and sandboxes removed, as kind/ID.
+	removed []string
+}
+
+func newFakeRuntimeClient(pods, containers int, now time.Time) *fakeRuntimeClient {
+	f := &fakeRuntimeClient{
+		podSandboxes:    make(map[string][]*runtimeapi.PodSandbox),
+		podContainers:   make(map[string][]*runtimeapi.Container),
+		sandboxStatus:   make(map[string]*runtimeapi.PodSandboxStatusResponse),
+		containerStatus: make(map[string]*runtimeapi.ContainerStatusResponse),
+		sandboxLists:    make(map[string]int),
+		containerLists:  make(map[string]int),
+	}
+	createdAt := now.Add(-time.Hour).UnixNano()
+	for i := 0; i < pods; i++ {
+		uid, name := fmt.Sprintf("uid-%d", i), fmt.Sprintf("pod-%d", i)
+		labels := map[string]string{
+			KubernetesPodUIDLabel:       uid,
+			KubernetesPodNameLabel:      name,
+			KubernetesPodNamespaceLabel: "default",
+		}
+		s := &runtimeapi.PodSandbox{
+			Id:        "sb-" + uid,
+			Metadata:  &runtimeapi.PodSandboxMetadata{Name: name, Namespace: "default", Uid: uid},
+			State:     runtimeapi.PodSandboxState_SANDBOX_READY,
+			CreatedAt: createdAt,
+			Labels:    labels,
+		}
+		f.sandboxes = append(f.sandboxes, s)
+		f.podSandboxes[uid] = []*runtimeapi.PodSandbox{s}
+		f.sandboxStatus[s.Id] = &runtimeapi.PodSandboxStatusResponse{Status: &runtimeapi.PodSandboxStatus{
+			Id: s.Id, Metadata: s.Metadata, State: s.State, CreatedAt: s.CreatedAt, Labels: labels,
+		}}
+		for j := 0; j < containers; j++ {
+			containerLabels := map[string]string{KubernetesContainerNameLabel: fmt.Sprintf("c%d", j)}
+			for k, v := range labels {
+				containerLabels[k] = v
+			}
+			c := &runtimeapi.Container{
+				Id:           fmt.Sprintf("c-%s-%d", uid, j),
+				PodSandboxId: s.Id,
+				Metadata:     &runtimeapi.ContainerMetadata{Name: fmt.Sprintf("c%d", j)},
+				Image:        &runtimeapi.ImageSpec{Image: "nginx:1.19"},
+				ImageRef:     "sha256:aaa",
+				State:        runtimeapi.ContainerState_CONTAINER_RUNNING,
+				CreatedAt:    createdAt,
+				Labels:       containerLabels,
+			}
+			f.containers = append(f.containers, c)
+			f.podContainers[uid] = append(f.podContainers[uid], c)
+			f.containerStatus[c.Id] = &runtimeapi.ContainerStatusResponse{Status: &runtimeapi.ContainerStatus{
+				Id: c.Id, Metadata: c.Metadata, State: c.State, CreatedAt: c.CreatedAt, StartedAt: c.CreatedAt,
+				Image: c.Image, ImageRef: c.ImageRef, Labels: containerLabels,
+			}}
+		}
+	}
+	return f
+}
+
+func (f *fakeRuntimeClient) ListPodSandbox(ctx context.Context, in *runtimeapi.ListPodSandboxRequest, opts ...grpc.CallOption) (*runtimeapi.ListPodSandboxResponse, error) {
+	if uid := in.GetFilter().GetLabelSelector()[KubernetesPodUIDLabel]; uid != "" {
+		f.sandboxLists[uid]++
+		return &runtimeapi.ListPodSandboxResponse{Items: f.podSandboxes[uid]}, nil
+	}
+	return &runtimeapi.ListPodSandboxResponse{Items: f.sandboxes}, nil
+}
+
+func (f *fakeRuntimeClient) ListContainers(ctx context.Context, in *runtimeapi.ListContainersRequest, opts ...grpc.CallOption) (*runtimeapi.ListContainersResponse, error) {
+	if uid := in.GetFilter().GetLabelSelector()[KubernetesPodUIDLabel]; uid != "" {
+		f.containerLists[uid]++
+		return &runtimeapi.ListContainersResponse{Containers: f.podContainers[uid]}, nil
+	}
+	return &runtimeapi.ListContainersResponse{Containers: f.containers}, nil
+}
+
+func (f *fakeRuntimeClient) PodSandboxStatus(ctx context.Context, in *runtimeapi.PodSandboxStatusRequest, opts ...grpc.CallOption) (*runtimeapi.PodSandboxStatusResponse, error) {
+	if f.hang[in.PodSandboxId] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return f.sandboxStatus[in.PodSandboxId], nil
+}
+
+func (f *fakeRuntimeClient) ContainerStatus(ctx context.Context, in *runtimeapi.ContainerStatusRequest, opts ...grpc.CallOption) (*runtimeapi.ContainerStatusResponse, error) {
+	return f.containerStatus[in.ContainerId], nil
+}
+
+func (f *fakeRuntimeClient) RemoveContainer(ctx context.Context, in *runtimeapi.RemoveContainerRequest, opts ...grpc.CallOption) (*runtimeapi.RemoveContainerResponse, error) {
+	f.removed = append(f.removed, "container/"+in.ContainerId)
+	return &runtimeapi.RemoveContainerResponse{}, nil
+}
+
+func (f *fakeRuntimeClient) RemovePodSandbox(ctx context.Context, in *runtimeapi.RemovePodSandboxRequest, opts ...grpc.CallOption) (*runtimeapi.RemovePodSandboxResponse, error) {
+	f.removed = append(f.removed, "sandbox/"+in.PodSandboxId)
+	return &runtimeapi.RemovePodSandboxResponse{}, nil
+}
+
+// newBenchRuntimeService returns a runtime service of a node of benchPods
+// pods, after a first relist filling the caches like a running watch.
+func newBenchRuntimeService() *runtimeService {
+	clock := monotonicClock{}
+	rs := &runtimeService{
+		Client:  newFakeRuntimeClient(benchPods, benchContainers, clock.Now()),
+		Timeout: time.Minute,
+		Stats:   newRPCStats(clock),
+		Clock:   clock,
+	}
+	relist(rs)
+	return rs
+}
+
+func BenchmarkRelist(b *testing.B) {
+	rs := newBenchRuntimeService()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := relist(rs); result.Err != nil {
+			b.Fatal(result.Err)
+		}
+	}
+}
+
+// TestRelistAllocations keeps a fast watch on a dense node from generating
+// constant GC pressure.
+func TestRelistAllocations(t *testing.T) {
+	rs := newBenchRuntimeService()
+	var result *relistResult
+	allocs := testing.AllocsPerRun(10, func() {
+		result = relist(rs)
+	})
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(result.Pods) != benchPods {
+		t.Fatalf("relisted %d pods, want %d", len(result.Pods), benchPods)
+	}
+	if allocs > relistAllocsBudget {
+		t.Errorf("relist of %d pods made %.0f allocations, budget is %d", benchPods, allocs, relistAllocsBudget)
+	}
+	t.Logf("relist of %d pods made %.0f allocations", benchPods, allocs)
+}
+
+// TestRelistListsEveryPod checks the status phase lists the sandboxes and
+// containers of every pod like the kubelet's GetPodStatus, the per-pod
+// latencies time these lists too.
+func TestRelistListsEveryPod(t *testing.T) {
+	rs := newBenchRuntimeService()
+	f := rs.Client.(*fakeRuntimeClient)
+	f.sandboxLists, f.containerLists = make(map[string]int), make(map[string]int)
+	result := relist(rs)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	for _, pod := range result.Pods {
+		if f.sandboxLists[pod.ID] != 1 || f.containerLists[pod.ID] != 1 {
+			t.Errorf("pod %s: %d sandbox and %d container lists, want 1 of each", pod.ID, f.sandboxLists[pod.ID], f.containerLists[pod.ID])
+		}
+	}
+	if len(f.sandboxLists) != benchPods || len(f.containerLists) != benchPods {
+		t.Errorf("lists filtered by %d and %d pods, want %d", len(f.sandboxLists), len(f.containerLists), benchPods)
+	}
+}
+
+// TestWatchdogTimeout checks a pod whose status hangs is abandoned once the
+// clock reaches podStatusTimeout.
+func TestWatchdogTimeout(t *testing.T) {
+	defer func(d time.Duration) { podStatusTimeout = d }(podStatusTimeout)
+	podStatusTimeout = 10 * time.Second
+	clock := newFakeClock()
+	f := newFakeRuntimeClient(1, benchContainers, clock.Now())
+	f.hang = map[string]bool{"sb-uid-0": true}
+	rs := &runtimeService{
+		Client:  f,
+		Timeout: time.Minute,
+		Stats:   newRPCStats(clock),
+		Clock:   clock,
+	}
+	done := make(chan *podResult, 1)
+	go func() {
+		result, err := rs.getPodStatusWithWatchdog(&Pod{ID: "uid-0", Name: "pod-0", Namespace: "default"})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Step(podStatusTimeout - time.Second)
+	select {
+	case <-done:
+		t.Fatal("pod abandoned before the timeout")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Step(time.Second)
+	if result := <-done; !result.TimedOut || result.Latency != podStatusTimeout {
+		t.Errorf("pod timed out %v after %v, want timed out after %v", result.TimedOut, result.Latency, podStatusTimeout)
+	}
+}
+
+// TestEmptySandboxes checks the sandboxes skipped for their missing metadata
+// are not reported as empty.
+func TestEmptySandboxes(t *testing.T) {
+	clock := newFakeClock()
+	f := newFakeRuntimeClient(1, 0, clock.Now())
+	f.sandboxes = append(f.sandboxes, &runtimeapi.PodSandbox{Id: "sb-without-metadata", State: runtimeapi.PodSandboxState_SANDBOX_READY})
+	rs := &runtimeService{
+		Client:  f,
+		Timeout: time.Minute,
+		Stats:   newRPCStats(clock),
+		Clock:   clock,
+	}
+	result := relist(rs)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(result.EmptySandboxes) != 1 || result.EmptySandboxes[0] != "sb-uid-0" {
+		t.Errorf("empty sandboxes %v, want [sb-uid-0]", result.EmptySandboxes)
+	}
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"io"
+	"io/ioutil"
+	"k8s.io/klog"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// sortBy orders the pods of the report.
+	sortBy = "namespace"
+	// output is the format of stdout, table, tree or json.
+	output = "table"
+	// quiet suppresses all logging but errors, so only the report is printed.
+	quiet = false
+	// durationUnit is the unit of the durations of the report, ms or s.
+	durationUnit = "ms"
+	// timeline prints the lifecycle of every container after the pod table.
+	timeline = false
+	// slowStart reports containers which took longer than this from created to started.
+	slowStart = time.Minute
+	// showMounts includes the mounts of every container in JSON reports.
+	showMounts = false
+	// mountWarning reports containers having more mounts than this, which
+	// slows down status calls on some runtimes.
+	mountWarning = 200
+)
+
+// podResult is the outcome of the status phase of one pod.
+type podResult struct {
+	ID         string
+	Name       string
+	Namespace  string
+	Sandboxes  []*sandboxResult
+	Containers []*containerResult
+	// CreatedAt is the creation time of the oldest container of the pod.
+	CreatedAt time.Time
+	// Latency is the time spent on the status calls of the pod.
+	Latency time.Duration
+	// TimedOut is set if the status calls were abandoned after -pod-status-timeout.
+	TimedOut bool
+}
+
+// containerResult is the outcome of the status call of one container.
+type containerResult struct {
+	ID       string
+	Name     string
+	State    string
+	Image    string
+	ImageRef string
+	// SandboxID is the sandbox the container runs in and Attempt the restart
+	// count of the kubelet, both from the list.
+	SandboxID string
+	Attempt   uint32
+	// Latency is the time spent on the status call of the container.
+	Latency time.Duration
+
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// ExitCode, Reason and Message are only set for exited containers.
+	ExitCode int32
+	Reason   string
+	Message  string
+
+	Mounts []*runtimeapi.Mount
+}
+
+// sandboxResult is a sandbox of a pod, as listed and then refreshed by its
+// status.
+type sandboxResult struct {
+	ID        string
+	PodUID    string
+	State     string
+	Attempt   uint32
+	CreatedAt time.Time
+	// Latency is the time spent on the status call of the sandbox, zero if
+	// it was only listed.
+	Latency time.Duration
+}
+
+func newSandboxResult(s *runtimeapi.PodSandbox) *sandboxResult {
+	return &sandboxResult{
+		ID:        s.Id,
+		PodUID:    s.GetMetadata().GetUid(),
+		State:     s.State.String(),
+		Attempt:   s.GetMetadata().GetAttempt(),
+		CreatedAt: unixNano(s.CreatedAt),
+	}
+}
+
+// update refreshes the sandbox from its status, which is more recent than the list.
+func (s *sandboxResult) update(status *runtimeapi.PodSandboxStatus) {
+	s.State = status.State.String()
+	s.CreatedAt = unixNano(status.CreatedAt)
+}
+
+// exited returns whether the container has terminated.
+func (c *containerResult) exited() bool {
+	return c.State == runtimeapi.ContainerState_CONTAINER_EXITED.String()
+}
+
+// termination is the reason of the exit, e.g. OOMKilled, or the exit code
+// when the runtime does not give one.
+func (c *containerResult) termination() string {
+	if c.Reason != "" {
+		return c.Reason
+	}
+	return fmt.Sprintf("ExitCode %d", c.ExitCode)
+}
+
+// StartDelay is the time from created to started, zero if not started.
+func (c *containerResult) StartDelay() time.Duration {
+	if c.StartedAt.IsZero() || c.CreatedAt.IsZero() {
+		return 0
+	}
+	return c.StartedAt.Sub(c.CreatedAt)
+}
+
+// RunTime is the time from started to finished, zero if not finished.
+func (c *containerResult) RunTime() time.Duration {
+	if c.FinishedAt.IsZero() || c.StartedAt.IsZero() {
+		return 0
+	}
+	return c.FinishedAt.Sub(c.StartedAt)
+}
+
+// unixNano converts a CRI timestamp, where zero means not specified.
+func unixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func newContainerResult(c *runtimeapi.Container) *containerResult {
+	return &containerResult{
+		ID:       c.Id,
+		Name:     c.GetMetadata().GetName(),
+		State:    c.State.String(),
+		Image:    c.GetImage().GetImage(),
+		ImageRef: c.ImageRef,
+
+		SandboxID: c.PodSandboxId,
+		Attempt:   c.GetMetadata().GetAttempt(),
+		CreatedAt: unixNano(c.CreatedAt),
+	}
+}
+
+// update refreshes the container from its status, which is more recent than the list.
+func (c *containerResult) update(status *runtimeapi.ContainerStatus) {
+	c.State = status.State.String()
+	if image := status.GetImage().GetImage(); image != "" {
+		c.Image = image
+	}
+	if status.ImageRef != "" {
+		c.ImageRef = status.ImageRef
+	}
+	c.CreatedAt = unixNano(status.CreatedAt)
+	c.StartedAt = unixNano(status.StartedAt)
+	c.FinishedAt = unixNano(status.FinishedAt)
+	if c.exited() {
+		c.ExitCode, c.Reason, c.Message = status.ExitCode, status.Reason, status.Message
+	}
+	c.Mounts = status.Mounts
+	if mountWarning > 0 && len(c.Mounts) > mountWarning {
+		klog.Warningf("Container %s has %d mounts", c.ID, len(c.Mounts))
+	}
+	if delay := c.StartDelay(); slowStart > 0 && delay > slowStart {
+		klog.Warningf("Container %s took %v from created to started", c.ID, delay)
+	}
+}
+
+// podLess orders pods by namespace, name and uid, so reports are stable between runs.
+func podLess(a, b *podResult) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.ID < b.ID
+}
+
+// sortPods orders the pods by key: latency, name, namespace, containers or age.
+// The slowest, biggest and oldest pods come first.
+func sortPods(pods []*podResult, key string) error {
+	var less func(a, b *podResult) bool
+	switch key {
+	case "latency":
+		less = func(a, b *podResult) bool { return a.Latency > b.Latency }
+	case "name":
+		less = func(a, b *podResult) bool { return a.Name < b.Name }
+	case "namespace":
+		less = func(a, b *podResult) bool { return a.Namespace < b.Namespace }
+	case "containers":
+		less = func(a, b *podResult) bool { return len(a.Containers) > len(b.Containers) }
+	case "age":
+		less = func(a, b *podResult) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	default:
+		return fmt.Errorf("unknown sort key %q", key)
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		if less(pods[i], pods[j]) {
+			return true
+		}
+		if less(pods[j], pods[i]) {
+			return false
+		}
+		return podLess(pods[i], pods[j])
+	})
+	return nil
+}
+
+// printTable writes the pods of the relist as a table.
+func printTable(out io.Writer, result *relistResult) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tUID\tSANDBOXES\tCONTAINERS\tAGE\tLATENCY\tSHARE\tIMAGES")
+	var sandboxStatus, containerStatus time.Duration
+	for _, pod := range result.Pods {
+		age := "<unknown>"
+		if !pod.CreatedAt.IsZero() {
+			age = result.Time.Sub(pod.CreatedAt).Round(time.Second).String()
+		}
+		var images []string
+		seen := make(map[string]bool)
+		for _, c := range pod.Containers {
+			if !seen[c.Image] {
+				seen[c.Image] = true
+				images = append(images, redacted("image", c.Image))
+			}
+		}
+		for _, s := range pod.Sandboxes {
+			sandboxStatus += s.Latency
+		}
+		for _, c := range pod.Containers {
+			containerStatus += c.Latency
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%.1f%%\t%s\n", redacted("ns", pod.Namespace), redacted("pod", pod.Name), pod.ID, len(pod.Sandboxes), len(pod.Containers), age, formatDuration(pod.Latency), result.share(pod.Latency), strings.Join(images, ","))
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nRelist: %s (list phase %s, status phase %s)\n", formatDuration(result.Duration), formatDuration(result.ListDuration), formatDuration(result.StatusDuration))
+	fmt.Fprintf(out, "Status calls: sandboxes %s, containers %s\n", formatDuration(sandboxStatus), formatDuration(containerStatus))
+
+	if result.RuntimeRestarts > 0 {
+		fmt.Fprintf(out, "Runtime restarted %d times during this session, last at %s\n", result.RuntimeRestarts, formatTime(result.RuntimeRestartedAt))
+	}
+	if len(result.Causes) > 0 {
+		fmt.Fprintf(out, "Likely causes: %s\n", strings.Join(result.Causes, ", "))
+	}
+	if result.Period > 0 {
+		fmt.Fprintf(out, "Relist spikes repeat every %v\n", result.Period)
+	}
+	if result.Inconsistencies > 0 {
+		fmt.Fprintf(out, "Inconsistencies: %d\n", result.Inconsistencies)
+	}
+	if parallelList {
+		fmt.Fprintf(out, "Parallel list saved %s of the list phase\n", formatDuration(result.ListSaved))
+	}
+	if h := result.Hedges; h != nil {
+		fmt.Fprintf(out, "Hedged %d status calls after %s, the second attempt answered first in %d (%.0f%%)\n", h.Hedged, formatDuration(hedgeAfter), h.Won, float64(h.Won)/float64(h.Hedged)*100)
+	}
+	if result.ClockSkew > 0 {
+		fmt.Fprintf(out, "Clock skew: runtime timestamps up to %s in the future\n", formatDuration(result.ClockSkew))
+	}
+	if result.Collisions > 0 {
+		fmt.Fprintf(out, "Pod collisions: %d\n", result.Collisions)
+	}
+	var timedOut []string
+	for _, pod := range result.Pods {
+		if pod.TimedOut {
+			timedOut = append(timedOut, redacted("ns", pod.Namespace)+"/"+redacted("pod", pod.Name))
+		}
+	}
+	if len(timedOut) > 0 {
+		fmt.Fprintf(out, "Pods timed out after %s: %s\n", formatDuration(podStatusTimeout), strings.Join(timedOut, ", "))
+	}
+	if len(result.EmptySandboxes) > 0 {
+		fmt.Fprintf(out, "Sandboxes without containers: %s\n", strings.Join(result.EmptySandboxes, ", "))
+	}
+	if len(result.OrphanContainers) > 0 {
+		fmt.Fprintf(out, "Containers without pods: %s\n", strings.Join(result.OrphanContainers, ", "))
+	}
+	if strict {
+		fmt.Fprintf(out, "Conformance violations: %d\n", result.Violations)
+	}
+	if terminations := countTerminations(result.Pods); len(terminations) > 0 {
+		var reasons []string
+		total := 0
+		for reason, n := range terminations {
+			reasons = append(reasons, fmt.Sprintf("%s: %d", reason, n))
+			total += n
+		}
+		sort.Strings(reasons)
+		fmt.Fprintf(out, "Exited containers: %d (%s)\n", total, strings.Join(reasons, ", "))
+	}
+
+	if timeline {
+		printTimeline(out, result)
+	}
+}
+
+// countTerminations counts the exited containers by termination reason.
+func countTerminations(pods []*podResult) map[string]int {
+	counts := make(map[string]int)
+	for _, pod := range pods {
+		for _, c := range pod.Containers {
+			if c.exited() {
+				counts[c.termination()]++
+			}
+		}
+	}
+	return counts
+}
+
+// printTimeline writes the lifecycle of every container, with the time spent
+// between the phases.
+func printTimeline(out io.Writer, result *relistResult) {
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tCONTAINER\tSTATE\tCREATED\tSTARTED\tFINISHED")
+	for _, pod := range result.Pods {
+		for _, c := range pod.Containers {
+			started, finished := "-", "-"
+			if !c.StartedAt.IsZero() {
+				started = fmt.Sprintf("%s (+%s)", formatTime(c.StartedAt), formatDuration(c.StartDelay()))
+			}
+			if !c.FinishedAt.IsZero() {
+				finished = fmt.Sprintf("%s (+%s)", formatTime(c.FinishedAt), formatDuration(c.RunTime()))
+			}
+			created := "-"
+			if !c.CreatedAt.IsZero() {
+				created = formatTime(c.CreatedAt)
+			}
+			fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\t%s\t%s\n", redacted("ns", pod.Namespace), redacted("pod", pod.Name), redacted("container", c.Name), c.State, created, started, finished)
+		}
+	}
+	w.Flush()
+}
+
+// durationValue converts d to durationUnit.
+func durationValue(d time.Duration) float64 {
+	if durationUnit == "s" {
+		return d.Seconds()
+	}
+	return float64(d) / float64(time.Millisecond)
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f%s", durationValue(d), durationUnit)
+}
+
+// formatTime formats timestamps of the report as ISO-8601 in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// report is the structured output of a relist. Durations are numbers in
+// DurationUnit, every duration has a raw nanoseconds twin for precision.
+// Changes must follow the compatibility policy of reportSchemaVersion.
+type report struct {
+	SchemaVersion string         `json:"schemaVersion"`
+	Time          string         `json:"time"`
+	Node          string         `json:"node"`
+	NodeInfo      *nodeInfo      `json:"nodeInfo,omitempty"`
+	DurationUnit  string         `json:"durationUnit"`
+	Duration      float64        `json:"duration"`
+	DurationNs    int64          `json:"durationNs"`
+	List          float64        `json:"list"`
+	ListNs        int64          `json:"listNs"`
+	Status        float64        `json:"status"`
+	StatusNs      int64          `json:"statusNs"`
+	Containers    int            `json:"containers"`
+	Terminations  map[string]int `json:"terminations"`
+	// Inconsistencies counts the calls of the relist disagreeing with each other.
+	Inconsistencies    int                   `json:"inconsistencies"`
+	Collisions         int                   `json:"collisions"`
+	EmptySandboxes     []string              `json:"emptySandboxes,omitempty"`
+	OrphanContainers   []string              `json:"orphanContainers,omitempty"`
+	Violations         int                   `json:"violations"`
+	ClockSkew          float64               `json:"clockSkew,omitempty"`
+	ClockSkewNs        int64                 `json:"clockSkewNs,omitempty"`
+	ListSaved          float64               `json:"listSaved,omitempty"`
+	ListSavedNs        int64                 `json:"listSavedNs,omitempty"`
+	Hedges             *hedgeCounts          `json:"hedges,omitempty"`
+	Interval           float64               `json:"interval,omitempty"`
+	IntervalNs         int64                 `json:"intervalNs,omitempty"`
+	Period             float64               `json:"period,omitempty"`
+	PeriodNs           int64                 `json:"periodNs,omitempty"`
+	Causes             []string              `json:"causes,omitempty"`
+	RuntimeRestarts    int                   `json:"runtimeRestarts,omitempty"`
+	RuntimeRestartedAt string                `json:"runtimeRestartedAt,omitempty"`
+	Pods               []*podReport          `json:"pods"`
+	Events             []*eventReport        `json:"events,omitempty"`
+	RPCs               map[string]*rpcReport `json:"rpcs"`
+	Host               *hostSnapshot         `json:"host,omitempty"`
+	RuntimeDaemon      *runtimeDaemonHealth  `json:"runtimeDaemon,omitempty"`
+	Self               *selfStats            `json:"self,omitempty"`
+}
+
+type podReport struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Namespace  string             `json:"namespace"`
+	Sandboxes  []*sandboxReport   `json:"sandboxes"`
+	Containers []*containerReport `json:"containers"`
+	CreatedAt  string             `json:"createdAt,omitempty"`
+	Latency    float64            `json:"latency"`
+	LatencyNs  int64              `json:"latencyNs"`
+	// Share is the percentage of the relist spent on the status calls of the pod.
+	Share    float64 `json:"share"`
+	TimedOut bool    `json:"timedOut,omitempty"`
+}
+
+type sandboxReport struct {
+	ID        string  `json:"id"`
+	State     string  `json:"state"`
+	Attempt   uint32  `json:"attempt"`
+	Latency   float64 `json:"latency"`
+	LatencyNs int64   `json:"latencyNs"`
+	CreatedAt string  `json:"createdAt,omitempty"`
+}
+
+type containerReport struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	State        string         `json:"state"`
+	Image        string         `json:"image"`
+	ImageRef     string         `json:"imageRef"`
+	Latency      float64        `json:"latency"`
+	LatencyNs    int64          `json:"latencyNs"`
+	CreatedAt    string         `json:"createdAt,omitempty"`
+	StartedAt    string         `json:"startedAt,omitempty"`
+	FinishedAt   string         `json:"finishedAt,omitempty"`
+	StartDelay   float64        `json:"startDelay"`
+	StartDelayNs int64          `json:"startDelayNs"`
+	RunTime      float64        `json:"runTime"`
+	RunTimeNs    int64          `json:"runTimeNs"`
+	ExitCode     *int32         `json:"exitCode,omitempty"`
+	Reason       string         `json:"reason,omitempty"`
+	Message      string         `json:"message,omitempty"`
+	Mounts       []*mountReport `json:"mounts,omitempty"`
+}
+
+type mountReport struct {
+	ContainerPath string `json:"containerPath"`
+	HostPath      string `json:"hostPath"`
+	Readonly      bool   `json:"readonly"`
+	Propagation   string `json:"propagation"`
+}
+
+// formatOptionalTime formats t, or returns "" for zero times.
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTime(t)
+}
+
+// eventReport is a PLEG event of the report and of the timeline on /events.
+type eventReport struct {
+	Time        string `json:"time"`
+	Relist      int    `json:"relist"`
+	Type        string `json:"type"`
+	PodID       string `json:"podID"`
+	ContainerID string `json:"containerID"`
+}
+
+func newEventReport(e *plegEvent) *eventReport {
+	return &eventReport{
+		Time:        formatTime(e.Time),
+		Relist:      e.Relist,
+		Type:        string(e.Type),
+		PodID:       e.PodID,
+		ContainerID: e.ContainerID,
+	}
+}
+
+type rpcReport struct {
+	Calls   int     `json:"calls"`
+	Errors  int     `json:"errors"`
+	Avg     float64 `json:"avg"`
+	AvgNs   int64   `json:"avgNs"`
+	Max     float64 `json:"max"`
+	MaxNs   int64   `json:"maxNs"`
+	Total   float64 `json:"total"`
+	TotalNs int64   `json:"totalNs"`
+	// Hedged are the calls which got a second attempt with -hedge-after, not
+	// counted in the fields above.
+	Hedged *hedgedRPCReport `json:"hedged,omitempty"`
+}
+
+// hedgedRPCReport are the hedged calls of a method, timed from the first
+// attempt to the answer used.
+type hedgedRPCReport struct {
+	Calls   int     `json:"calls"`
+	Errors  int     `json:"errors"`
+	Avg     float64 `json:"avg"`
+	AvgNs   int64   `json:"avgNs"`
+	Max     float64 `json:"max"`
+	MaxNs   int64   `json:"maxNs"`
+	Total   float64 `json:"total"`
+	TotalNs int64   `json:"totalNs"`
+}
+
+func newReport(result *relistResult) *report {
+	r := &report{
+		SchemaVersion:      reportSchemaVersion,
+		Time:               formatTime(result.Time),
+		Node:               nodeName,
+		NodeInfo:           result.NodeInfo,
+		DurationUnit:       durationUnit,
+		Duration:           durationValue(result.Duration),
+		DurationNs:         int64(result.Duration),
+		List:               durationValue(result.ListDuration),
+		ListNs:             int64(result.ListDuration),
+		Status:             durationValue(result.StatusDuration),
+		StatusNs:           int64(result.StatusDuration),
+		Containers:         result.Containers,
+		Terminations:       countTerminations(result.Pods),
+		Inconsistencies:    result.Inconsistencies,
+		Collisions:         result.Collisions,
+		EmptySandboxes:     result.EmptySandboxes,
+		OrphanContainers:   result.OrphanContainers,
+		Violations:         result.Violations,
+		ClockSkew:          durationValue(result.ClockSkew),
+		ClockSkewNs:        int64(result.ClockSkew),
+		ListSaved:          durationValue(result.ListSaved),
+		ListSavedNs:        int64(result.ListSaved),
+		Hedges:             result.Hedges,
+		Interval:           durationValue(result.Interval),
+		IntervalNs:         int64(result.Interval),
+		RuntimeRestarts:    result.RuntimeRestarts,
+		RuntimeRestartedAt: formatOptionalTime(result.RuntimeRestartedAt),
+		Period:             durationValue(result.Period),
+		PeriodNs:           int64(result.Period),
+		Causes:             result.Causes,
+		Pods:               make([]*podReport, 0, len(result.Pods)),
+		RPCs:               make(map[string]*rpcReport, len(result.RPCs)),
+		Host:               result.Host,
+		RuntimeDaemon:      result.RuntimeDaemon,
+		Self:               result.Self,
+	}
+	for _, pod := range result.Pods {
+		p := &podReport{
+			ID:         pod.ID,
+			Name:       redacted("pod", pod.Name),
+			Namespace:  redacted("ns", pod.Namespace),
+			Sandboxes:  make([]*sandboxReport, 0, len(pod.Sandboxes)),
+			Containers: make([]*containerReport, 0, len(pod.Containers)),
+			Latency:    durationValue(pod.Latency),
+			LatencyNs:  int64(pod.Latency),
+			Share:      result.share(pod.Latency),
+			TimedOut:   pod.TimedOut,
+		}
+		for _, s := range pod.Sandboxes {
+			p.Sandboxes = append(p.Sandboxes, &sandboxReport{
+				ID:        s.ID,
+				State:     s.State,
+				Attempt:   s.Attempt,
+				Latency:   durationValue(s.Latency),
+				LatencyNs: int64(s.Latency),
+				CreatedAt: formatOptionalTime(s.CreatedAt),
+			})
+		}
+		for _, c := range pod.Containers {
+			cr := &containerReport{
+				ID:           c.ID,
+				Name:         redacted("container", c.Name),
+				State:        c.State,
+				Image:        redacted("image", c.Image),
+				ImageRef:     redacted("image", c.ImageRef),
+				Latency:      durationValue(c.Latency),
+				LatencyNs:    int64(c.Latency),
+				CreatedAt:    formatOptionalTime(c.CreatedAt),
+				StartedAt:    formatOptionalTime(c.StartedAt),
+				FinishedAt:   formatOptionalTime(c.FinishedAt),
+				StartDelay:   durationValue(c.StartDelay()),
+				StartDelayNs: int64(c.StartDelay()),
+				RunTime:      durationValue(c.RunTime()),
+				RunTimeNs:    int64(c.RunTime()),
+			}
+			if c.exited() {
+				exitCode := c.ExitCode
+				cr.ExitCode, cr.Reason, cr.Message = &exitCode, c.Reason, c.Message
+			}
+			if showMounts {
+				// Kubelet host paths embed the namespace, pod and container names.
+				for _, m := range c.Mounts {
+					cr.Mounts = append(cr.Mounts, &mountReport{
+						ContainerPath: m.ContainerPath,
+						HostPath:      redacted("path", m.HostPath),
+						Readonly:      m.Readonly,
+						Propagation:   m.Propagation.String(),
+					})
+				}
+			}
+			p.Containers = append(p.Containers, cr)
+		}
+		p.CreatedAt = formatOptionalTime(pod.CreatedAt)
+		r.Pods = append(r.Pods, p)
+	}
+	for _, e := range result.Events {
+		r.Events = append(r.Events, newEventReport(e))
+	}
+	for method, m := range result.RPCs {
+		r.RPCs[method] = &rpcReport{
+			Calls:   m.Calls,
+			Errors:  m.Errors,
+			Avg:     durationValue(m.Avg()),
+			AvgNs:   int64(m.Avg()),
+			Max:     durationValue(m.Max),
+			MaxNs:   int64(m.Max),
+			Total:   durationValue(m.Total),
+			TotalNs: int64(m.Total),
+		}
+		if h := m.Hedged; h != nil {
+			r.RPCs[method].Hedged = &hedgedRPCReport{
+				Calls:   h.Calls,
+				Errors:  h.Errors,
+				Avg:     durationValue(h.Avg()),
+				AvgNs:   int64(h.Avg()),
+				Max:     durationValue(h.Max),
+				MaxNs:   int64(h.Max),
+				Total:   durationValue(h.Total),
+				TotalNs: int64(h.Total),
+			}
+		}
+	}
+	return r
+}
+
+// writeResult writes the relist in format, JSON reports are indented if pretty
+// and written on one line otherwise.
+func writeResult(out io.Writer, format string, pretty bool, result *relistResult) error {
+	switch format {
+	case "table":
+		printTable(out, result)
+		return nil
+	case "tree":
+		printTree(out, result)
+		return nil
+	case "json":
+		enc := json.NewEncoder(out)
+		if pretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(newReport(result))
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// setupQuiet discards all logging but errors, which still go to stderr.
+func setupQuiet(set func(name, value string) error) {
+	if !quiet {
+		return
+	}
+	set("logtostderr", "false")
+	set("stderrthreshold", "ERROR")
+	klog.SetOutput(ioutil.Discard)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// methodStats are the latencies of the calls of one CRI method.
+type methodStats struct {
+	Calls  int
+	Errors int
+	Total  time.Duration
+	Max    time.Duration
+	Last   time.Duration
+	// Hedged are the calls which got a second attempt, timed from the first
+	// attempt to the answer used. They are not counted in the fields above,
+	// which time single attempts.
+	Hedged *methodStats
+}
+
+// AllCalls counts the calls, hedged or not.
+func (m *methodStats) AllCalls() int {
+	if m.Hedged == nil {
+		return m.Calls
+	}
+	return m.Calls + m.Hedged.Calls
+}
+
+// Slowest is the latency of the slowest call, hedged or not.
+func (m *methodStats) Slowest() time.Duration {
+	if m.Hedged != nil && m.Hedged.Max > m.Max {
+		return m.Hedged.Max
+	}
+	return m.Max
+}
+
+// Avg is the mean latency of the calls.
+func (m *methodStats) Avg() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	return m.Total / time.Duration(m.Calls)
+}
+
+// rpcStats records the latency of every CRI call, grouped by method.
+type rpcStats struct {
+	mu      sync.Mutex
+	methods map[string]*methodStats
+	// recent are the latest latencies of every method, kept across resets.
+	recent map[string]*latencyRing
+	clock  clock
+}
+
+func newRPCStats(clock clock) *rpcStats {
+	return &rpcStats{
+		clock:   clock,
+		methods: make(map[string]*methodStats),
+		recent:  make(map[string]*latencyRing),
+	}
+}
+
+// intercept is a grpc.UnaryClientInterceptor timing the calls. It is chained
+// before hedgeCall, which flags the calls it hedges.
+func (s *rpcStats) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	// "/runtime.v1alpha2.RuntimeService/ListPodSandbox" -> "ListPodSandbox"
+	name := method[strings.LastIndexByte(method, '/')+1:]
+	var hedged int32
+	if hedgeAfter > 0 && hedgeMethods[name] {
+		ctx = context.WithValue(ctx, hedgedKey{}, &hedged)
+	}
+	now := s.clock.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if abandoned(ctx) {
+		return err
+	}
+	s.observe(name, s.clock.Since(now), err, atomic.LoadInt32(&hedged) == 1)
+	return err
+}
+
+// observe records a call. The hedged calls are recorded apart and kept out
+// of the recent latencies.
+func (s *rpcStats) observe(method string, elapsed time.Duration, err error, hedged bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, found := s.methods[method]
+	if !found {
+		m = &methodStats{}
+		s.methods[method] = m
+	}
+	if hedged {
+		if m.Hedged == nil {
+			m.Hedged = &methodStats{}
+		}
+		m = m.Hedged
+	}
+	m.Calls++
+	if err != nil {
+		m.Errors++
+	}
+	m.Total += elapsed
+	m.Last = elapsed
+	if elapsed > m.Max {
+		m.Max = elapsed
+	}
+	if hedged {
+		return
+	}
+
+	r, found := s.recent[method]
+	if !found {
+		r = &latencyRing{}
+		s.recent[method] = r
+	}
+	r.add(latencySample{Time: s.clock.Now(), Latency: elapsed})
+}
+
+// heatmaps returns the heatmaps of the recent latencies of every method.
+func (s *rpcStats) heatmaps() []*heatmap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var heatmaps []*heatmap
+	for method, r := range s.recent {
+		heatmaps = append(heatmaps, newHeatmap(method, r.all()))
+	}
+	sort.Slice(heatmaps, func(i, j int) bool { return heatmaps[i].Method < heatmaps[j].Method })
+	return heatmaps
+}
+
+// reset returns the stats recorded so far and starts over.
+func (s *rpcStats) reset() map[string]*methodStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	methods := s.methods
+	s.methods = make(map[string]*methodStats)
+	return methods
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/klog"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	// checkRuntimeConfig calls UpdateRuntimeConfig with the pod CIDR of the node.
+	checkRuntimeConfig = false
+	// podCIDR is the pod CIDR of the node, read from kubeletConfig if empty.
+	podCIDR       = ""
+	kubeletConfig = "/var/lib/kubelet/config.yaml"
+)
+
+// readPodCIDR reads the podCIDR field of the kubelet configuration file.
+func readPodCIDR(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "podCIDR:") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "podCIDR:")), `"'`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no podCIDR in %s", path)
+}
+
+// checkUpdateRuntimeConfig passes the pod CIDR to the runtime like the kubelet
+// does when the node is assigned one, and times the call.
+func (rs *runtimeService) checkUpdateRuntimeConfig() error {
+	cidr := podCIDR
+	if cidr == "" {
+		var err error
+		if cidr, err = readPodCIDR(kubeletConfig); err != nil {
+			return err
+		}
+	}
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	defer cancel()
+	now := rs.Clock.Now()
+	_, err := rs.Client.UpdateRuntimeConfig(ctx, &runtimeapi.UpdateRuntimeConfigRequest{
+		RuntimeConfig: &runtimeapi.RuntimeConfig{
+			NetworkConfig: &runtimeapi.NetworkConfig{PodCidr: cidr},
+		},
+	})
+	elapsed := rs.Clock.Since(now)
+	if err != nil {
+		return fmt.Errorf("runtime rejected pod CIDR %s after %v: %v", cidr, elapsed, err)
+	}
+	klog.V(2).Infof("UpdateRuntimeConfig pod CIDR %s, Threshold: %v", cidr, colorDuration(elapsed, relistThreshold))
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/coderwangke/oncepleg/internal/platform"
+	"k8s.io/klog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exhaustionRatio is the fraction of a limit (open files, threads) above which the
+// runtime daemon is reported as close to exhaustion.
+const exhaustionRatio = 0.9
+
+// runtimeDaemonNames are the process names of known CRI runtimes, keyed by a
+// substring of their default endpoint.
+var runtimeDaemonNames = []struct {
+	Endpoint string
+	Comm     string
+}{
+	{"dockershim", "dockerd"},
+	{"containerd", "containerd"},
+	{"crio", "crio"},
+}
+
+// runtimeDaemon is the process serving the CRI endpoint.
+type runtimeDaemon struct {
+	Pid   int
+	Comm  string
+	start *platform.Usage
+	clock clock
+	since time.Time
+}
+
+// runtimeDaemonHealth is the resource usage of the runtime daemon during a relist.
+type runtimeDaemonHealth struct {
+	Pid  int    `json:"pid"`
+	Comm string `json:"comm"`
+	// CPU is the cpu usage in percent of one core during the relist.
+	CPU        float64       `json:"cpu"`
+	CPUTime    time.Duration `json:"cpuTimeNs"`
+	RSS        int64         `json:"rss"`
+	Threads    int           `json:"threads"`
+	OpenFDs    int           `json:"openFDs"`
+	MaxOpenFDs int           `json:"maxOpenFDs"`
+}
+
+// findRuntimeDaemon locates the runtime daemon process, preferring the one
+// matching the endpoint.
+func findRuntimeDaemon(endpoint string, c clock) *runtimeDaemon {
+	pids, err := processes.Commands()
+	if err != nil {
+		return nil
+	}
+
+	comm := ""
+	for _, d := range runtimeDaemonNames {
+		if _, found := pids[d.Comm]; found && strings.Contains(endpoint, d.Endpoint) {
+			comm = d.Comm
+			break
+		}
+	}
+	if comm == "" {
+		for _, d := range runtimeDaemonNames {
+			if _, found := pids[d.Comm]; found {
+				comm = d.Comm
+				break
+			}
+		}
+	}
+	if comm == "" {
+		return nil
+	}
+
+	usage, err := processes.Usage(pids[comm])
+	if err != nil {
+		return nil
+	}
+	return &runtimeDaemon{
+		Pid:   pids[comm],
+		Comm:  comm,
+		start: usage,
+		clock: c,
+		since: c.Now(),
+	}
+}
+
+// health reads the current resource usage of the runtime daemon, the cpu usage
+// is computed since the daemon was found.
+func (d *runtimeDaemon) health() (*runtimeDaemonHealth, error) {
+	usage, err := processes.Usage(d.Pid)
+	if err != nil {
+		return nil, err
+	}
+	if usage.Start != d.start.Start {
+		return nil, fmt.Errorf("runtime daemon %s (pid %d) restarted", d.Comm, d.Pid)
+	}
+
+	h := &runtimeDaemonHealth{
+		Pid:        d.Pid,
+		Comm:       d.Comm,
+		CPUTime:    usage.CPUTime,
+		RSS:        usage.RSS,
+		Threads:    usage.Threads,
+		OpenFDs:    usage.OpenFDs,
+		MaxOpenFDs: usage.MaxOpenFDs,
+	}
+	if elapsed := d.clock.Since(d.since); elapsed > 0 {
+		h.CPU = float64(usage.CPUTime-d.start.CPUTime) * 100 / float64(elapsed)
+	}
+	return h, nil
+}
+
+// check reports resource exhaustion of the runtime daemon.
+func (h *runtimeDaemonHealth) check() {
+	if h.OpenFDs >= 0 && h.MaxOpenFDs > 0 && float64(h.OpenFDs) >= float64(h.MaxOpenFDs)*exhaustionRatio {
+		klog.Warningf("Runtime daemon %s (pid %d) is close to its open files limit: %d/%d", h.Comm, h.Pid, h.OpenFDs, h.MaxOpenFDs)
+	}
+	if max := readIntFile(filepath.Join(procRoot, "sys/kernel/threads-max")); max > 0 && float64(h.Threads) >= float64(max)*exhaustionRatio {
+		klog.Warningf("Runtime daemon %s (pid %d) is close to the threads limit: %d/%d", h.Comm, h.Pid, h.Threads, max)
+	}
+}
+
+func (h *runtimeDaemonHealth) String() string {
+	fds := strconv.Itoa(h.OpenFDs)
+	if h.MaxOpenFDs > 0 {
+		fds = fmt.Sprintf("%d/%d", h.OpenFDs, h.MaxOpenFDs)
+	}
+	return fmt.Sprintf("%s (pid %d): cpu %.1f%% during relist, cpu time %v, rss %.1fMiB, threads %d, open fds %s",
+		h.Comm, h.Pid, h.CPU, h.CPUTime, float64(h.RSS)/(1<<20), h.Threads, fds)
+}
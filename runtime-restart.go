@@ -0,0 +1,43 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"strconv"
+	"time"
+)
+
+var (
+	// runtimeRestarts counts the runtime restarts seen during this session.
+	runtimeRestarts int
+	// runtimeRestartedAt is the time of the last of them, zero if none.
+	runtimeRestartedAt time.Time
+	// lastDaemon is the runtime daemon found by the previous relist.
+	lastDaemon *runtimeDaemon
+)
+
+// recordRuntimeRestart counts a runtime restart noticed at t.
+func recordRuntimeRestart(t time.Time, hint string) {
+	runtimeRestarts++
+	runtimeRestartedAt = t
+	klog.Warningf("Runtime of %s restarted at %s (%s), %d restarts during this session", remoteRuntimeEndpoint, formatTime(t), hint, runtimeRestarts)
+}
+
+// checkDaemonRestart compares the runtime daemon with the one of the previous
+// relist, a new pid or start time means the runtime restarted in between.
+// A restart already noticed by the socket watch is not counted twice.
+func checkDaemonRestart(daemon *runtimeDaemon) {
+	previous := lastDaemon
+	if daemon != nil {
+		lastDaemon = daemon
+	}
+	if previous == nil || daemon == nil {
+		return
+	}
+	if daemon.Pid == previous.Pid && daemon.start.Start == previous.start.Start {
+		return
+	}
+	if runtimeRestartedAt.After(previous.since) {
+		return
+	}
+	recordRuntimeRestart(daemon.since, "daemon pid "+strconv.Itoa(previous.Pid)+" -> "+strconv.Itoa(daemon.Pid))
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
 	"k8s.io/klog"
 	"net"
 	"net/url"
+	"sort"
 	"time"
 )
 
@@ -22,8 +25,49 @@ var (
 )
 
 type runtimeService struct {
-	Client  runtimeapi.RuntimeServiceClient
+	Client runtimeapi.RuntimeServiceClient
+	// Images is the image service, served on the same endpoint like the
+	// kubelet does by default.
+	Images  runtimeapi.ImageServiceClient
 	Timeout time.Duration
+	// Stats records the latency of every call.
+	Stats *rpcStats
+	// Clock times the calls and relists.
+	Clock clock
+	conn  *grpc.ClientConn
+	// ctx is the parent of the calls, cancelled by the watchdog when it
+	// abandons a pod. Nil is context.Background().
+	ctx context.Context
+
+	// containers are the containers seen by the last relist.
+	containers map[string]containerRecord
+	// sandboxes are the sandboxes listed by the current relist by ID.
+	sandboxes map[string]*sandboxResult
+	// listSaved is how much the concurrent list calls of -parallel-list
+	// shortened the list phase of the current relist.
+	listSaved time.Duration
+	// spareRecords is the records map of an older relist, reused by the next
+	// list.
+	spareRecords map[string]containerRecord
+	// metadata caches the immutable metadata of the containers of the last
+	// list by ID.
+	metadata map[string]*containerMetadata
+	// inconsistencies counts the disagreements between the calls of the
+	// current relist, e.g. containers of sandboxes which were not listed.
+	inconsistencies int
+	// collisions counts the ready sandboxes claiming the pod UID or the
+	// namespace/name of another ready sandbox in the current relist.
+	collisions int
+	// emptySandboxes are the sandboxes without containers and orphanContainers
+	// the containers without a pod UID label in the current relist.
+	emptySandboxes   []string
+	orphanContainers []string
+	// clockSkew is the furthest in the future a container timestamp was in the current relist.
+	clockSkew time.Duration
+	// violations counts the conformance violations of the current relist in strict mode.
+	violations int
+	// node is the info of the node, read on the first relist.
+	node *nodeInfo
 }
 
 // Pod is a group of containers.
@@ -34,9 +78,35 @@ type Pod struct {
 	// The name and namespace of the pod, which is readable by human.
 	Name      string
 	Namespace string
+	// SandboxIDs are the sandboxes of the pod in list order.
+	SandboxIDs []string
+	// Containers are the containers of the pod as listed, before their status.
+	Containers []*containerResult
+	// CreatedAt is the creation time of the oldest sandbox of the pod, zero
+	// if the pod only has containers.
+	CreatedAt time.Time
 }
 
 func newRuntimeServiceClient(endpoint string, connectionTimeout time.Duration) (*runtimeService, error) {
+	clock := monotonicClock{}
+	stats := newRPCStats(clock)
+	conn, err := dialRuntimeService(endpoint, connectionTimeout, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runtimeService{
+		Client:  runtimeapi.NewRuntimeServiceClient(conn),
+		Images:  runtimeapi.NewImageServiceClient(conn),
+		Timeout: connectionTimeout,
+		Stats:   stats,
+		Clock:   clock,
+		conn:    conn,
+	}, nil
+
+}
+
+func dialRuntimeService(endpoint string, connectionTimeout time.Duration, stats *rpcStats) (*grpc.ClientConn, error) {
 	klog.V(5).Infof("Connecting to runtime service %s", endpoint)
 	addr, dailer, err := getAddressAndDialer(endpoint)
 	if err != nil {
@@ -45,17 +115,24 @@ func newRuntimeServiceClient(endpoint string, connectionTimeout time.Duration) (
 	ctx, cancel := context.WithTimeout(context.Background(), connectionTimeout)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithDialer(dailer), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)))
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithDialer(dailer), grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)), grpc.WithUserAgent(userAgent()), grpc.WithChainUnaryInterceptor(tagCall, enforceReadOnly, stats.intercept, hedgeCall(stats.clock), auditCall))
 	if err != nil {
 		klog.Errorf("Connect remote runtime %s failed: %v", addr, err)
 		return nil, err
 	}
+	return conn, nil
+}
 
-	return &runtimeService{
-		Client:  runtimeapi.NewRuntimeServiceClient(conn),
-		Timeout: connectionTimeout,
-	}, nil
-
+// reconnect replaces the connection to the runtime, keeping the stats.
+func (rs *runtimeService) reconnect() error {
+	conn, err := dialRuntimeService(remoteRuntimeEndpoint, rs.Timeout, rs.Stats)
+	if err != nil {
+		return err
+	}
+	rs.conn.Close()
+	rs.conn = conn
+	rs.Client, rs.Images = runtimeapi.NewRuntimeServiceClient(conn), runtimeapi.NewImageServiceClient(conn)
+	return nil
 }
 
 func getAddressAndDialer(endpoint string) (string, func(addr string, timeout time.Duration) (net.Conn, error), error) {
@@ -75,62 +152,125 @@ func dial(addr string, timeout time.Duration) (net.Conn, error) {
 }
 
 func (rs *runtimeService) getPods() ([]*Pod, error) {
-	now := time.Now()
+	now := rs.Clock.Now()
 	pods, err := rs._getPods()
 	if err != nil {
 		return nil, err
 	}
-	elapsed := time.Since(now)
-	klog.V(2).Infof("List all Pods, Threshold: %v\n", elapsed)
+	elapsed := rs.Clock.Since(now)
+	if klog.V(2) {
+		klog.Infof("List all Pods, Threshold: %v\n", colorDuration(elapsed, relistThreshold))
+	}
 	return pods, nil
 }
 
-func (rs *runtimeService) getPodStatus(uid, name, namespace string) error {
-	now := time.Now()
-	err := rs._getPodStatus(uid, name, namespace)
+func (rs *runtimeService) getPodStatus(uid, name, namespace string) (*podResult, error) {
+	now := rs.Clock.Now()
+	result := &podResult{
+		ID:        uid,
+		Name:      name,
+		Namespace: namespace,
+	}
+	err := rs._getPodStatus(uid, name, namespace, result)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	elapsed := rs.Clock.Since(now)
+	result.Latency = elapsed
+	if klog.V(2) {
+		klog.Infof("List pod %s/%s Status, Threshold: %v\n", name, namespace, colorDuration(elapsed, relistThreshold))
 	}
-	elapsed := time.Since(now)
-	klog.V(2).Infof("List pod %s Status, Threshold: %v\n", fmt.Sprintf("%s/%s", name, namespace), elapsed)
 
-	return nil
+	return result, nil
 }
 
 func (rs *runtimeService) _getPods() ([]*Pod, error) {
-	pods := make(map[string]*Pod)
-	sandboxes, err := rs.getKubeletSandboxs("", true)
+	sandboxes, containers, err := rs.listAll()
 	if err != nil {
 		return nil, err
 	}
+	pods := make(map[string]*Pod, len(sandboxes))
+	sandboxIDs := make(map[string]bool, len(sandboxes))
+	rs.sandboxes = make(map[string]*sandboxResult, len(sandboxes))
+	// Older sandboxes of a pod are kept until garbage collected, only the
+	// ready sandboxes must be unique.
+	readyUIDs := make(map[string]*runtimeapi.PodSandbox, len(sandboxes))
+	readyNames := make(map[string]*runtimeapi.PodSandbox, len(sandboxes))
 	for i := range sandboxes {
 		s := sandboxes[i]
+		sandboxIDs[s.Id] = true
 		if s.Metadata == nil {
 			klog.V(2).Infof("Sandbox does not have metadata: %+v", s)
+			rs.violation("Sandbox %s does not have metadata", s.Id)
 			continue
 		}
 		podUID := s.Metadata.Uid
-		if _, ok := pods[podUID]; !ok {
-			pods[podUID] = &Pod{
+		rs.sandboxes[s.Id] = newSandboxResult(s)
+		if s.State == runtimeapi.PodSandboxState_SANDBOX_READY {
+			if other, ok := readyUIDs[podUID]; ok {
+				rs.collisions++
+				klog.Warningf("Sandboxes %s (%s/%s) and %s (%s/%s) are both ready for pod UID %s", other.Id, other.Metadata.Namespace, other.Metadata.Name, s.Id, s.Metadata.Namespace, s.Metadata.Name, podUID)
+			} else {
+				readyUIDs[podUID] = s
+			}
+			name := s.Metadata.Namespace + "/" + s.Metadata.Name
+			if other, ok := readyNames[name]; ok && other.Metadata.Uid != podUID {
+				rs.collisions++
+				klog.Warningf("Sandboxes %s (UID %s) and %s (UID %s) are both ready for pod %s", other.Id, other.Metadata.Uid, s.Id, podUID, name)
+			} else if !ok {
+				readyNames[name] = s
+			}
+		}
+		pod, ok := pods[podUID]
+		if !ok {
+			pod = &Pod{
 				ID:        podUID,
 				Name:      s.Metadata.Name,
 				Namespace: s.Metadata.Namespace,
 			}
+			pods[podUID] = pod
+		}
+		pod.SandboxIDs = append(pod.SandboxIDs, s.Id)
+		if createdAt := unixNano(s.CreatedAt); pod.CreatedAt.IsZero() || createdAt.Before(pod.CreatedAt) {
+			pod.CreatedAt = createdAt
 		}
 	}
 
-	containers, err := rs.getKubeletContainers("", true)
-	if err != nil {
-		return nil, err
+	// The records of the relist before the previous one are no longer needed.
+	records := rs.spareRecords
+	rs.spareRecords = nil
+	if records == nil {
+		records = make(map[string]containerRecord, len(containers))
+	}
+	for id := range records {
+		delete(records, id)
 	}
+	metadata := make(map[string]*containerMetadata, len(containers))
+	sandboxContainers := make(map[string]int, len(sandboxes))
 	for i := range containers {
 		c := containers[i]
 		if c.Metadata == nil {
 			klog.V(2).Infof("Container does not have metadata: %+v", c)
+			rs.violation("Container %s does not have metadata", c.Id)
 			continue
 		}
+		meta := rs.cachedMetadata(c, metadata)
+		rs.checkContainerLabels(c.Id, meta.missingLabels)
+
+		if !sandboxIDs[c.PodSandboxId] {
+			rs.inconsistencies++
+			klog.Warningf("Container %s references sandbox %s which was not listed", c.Id, c.PodSandboxId)
+		}
 
-		labelledInfo := getContainerInfoFromLabels(c.Labels)
+		labelledInfo := meta.labels
+		records[c.Id] = containerRecord{PodID: labelledInfo.PodUID, State: c.State}
+		sandboxContainers[c.PodSandboxId]++
+		if labelledInfo.PodUID == "" {
+			// Do not group unrelated containers in a pod with an empty UID.
+			rs.orphanContainers = append(rs.orphanContainers, c.Id)
+			klog.Warningf("Container %s does not belong to any pod, it has no pod UID label", c.Id)
+			continue
+		}
 		pod, found := pods[labelledInfo.PodUID]
 		if !found {
 			pod = &Pod{
@@ -140,10 +280,22 @@ func (rs *runtimeService) _getPods() ([]*Pod, error) {
 			}
 			pods[labelledInfo.PodUID] = pod
 		}
+		pod.Containers = append(pod.Containers, meta.result(c.State))
+	}
+
+	// The sandboxes without metadata were skipped, they are no pod's.
+	for id := range rs.sandboxes {
+		if sandboxContainers[id] == 0 {
+			rs.emptySandboxes = append(rs.emptySandboxes, id)
+			klog.V(2).Infof("Sandbox %s does not have containers", id)
+		}
 	}
+	sort.Strings(rs.emptySandboxes)
+	rs.containers = records
+	rs.metadata = metadata
 
 	// Convert map to list.
-	var result []*Pod
+	result := make([]*Pod, 0, len(pods))
 	for _, pod := range pods {
 		result = append(result, pod)
 	}
@@ -151,21 +303,37 @@ func (rs *runtimeService) _getPods() ([]*Pod, error) {
 	return result, nil
 }
 
-func (rs *runtimeService) _getPodStatus(uid, name, namespace string) error {
-	klog.V(2).Infof("Pod ID: %s, Name: %s, Namespace: %s\n", uid, name, namespace)
+func (rs *runtimeService) _getPodStatus(uid, name, namespace string, result *podResult) error {
+	if klog.V(2) {
+		klog.Infof("Pod ID: %s, Name: %s, Namespace: %s\n", uid, name, namespace)
+	}
 	// get sandbox by uid
-	sandboxes, err := rs.getKubeletContainers(uid, true)
+	sandboxes, err := rs.getKubeletSandboxs(uid, true)
 	if err != nil {
 		return err
 	}
 	if len(sandboxes) != 0 {
-		for _, sandbox := range sandboxes {
-			klog.V(2).Infof("Sandbox ID: %s", sandbox.Id)
-			err := rs.getPodSandboxStatus(sandbox.Id)
+		result.Sandboxes = make([]*sandboxResult, 0, len(sandboxes))
+		for _, s := range sandboxes {
+			sandbox := newSandboxResult(s)
+			result.Sandboxes = append(result.Sandboxes, sandbox)
+
+			if klog.V(2) {
+				klog.Infof("Sandbox ID: %s", s.Id)
+			}
+			now := rs.Clock.Now()
+			resp, err := rs.getPodSandboxStatus(s.Id)
+			sandbox.Latency = rs.Clock.Since(now)
+			if grpcstatus.Code(err) == codes.NotFound {
+				rs.inconsistencies++
+				klog.Warningf("Sandbox %s was listed but its status was not found", s.Id)
+				continue
+			}
 			if err != nil {
-				klog.Errorf("PodSandboxStatus of sandbox %q for pod %q error: %v", sandbox.Id, name, err)
+				klog.Errorf("PodSandboxStatus of sandbox %q for pod %q error: %v", s.Id, name, err)
 				continue
 			}
+			sandbox.update(resp.Status)
 		}
 	}
 
@@ -175,55 +343,108 @@ func (rs *runtimeService) _getPodStatus(uid, name, namespace string) error {
 		return err
 	}
 	if len(containers) != 0 {
+		result.Containers = make([]*containerResult, 0, len(containers))
 		for _, c := range containers {
-			klog.V(2).Infof("Container ID: %s", c.Id)
-			err := rs.getContainerStatus(c.Id)
+			if createdAt := time.Unix(0, c.CreatedAt); result.CreatedAt.IsZero() || createdAt.Before(result.CreatedAt) {
+				result.CreatedAt = createdAt
+			}
+			container := rs.newContainerResult(c)
+			result.Containers = append(result.Containers, container)
+
+			if klog.V(2) {
+				klog.Infof("Container ID: %s", c.Id)
+			}
+			now := rs.Clock.Now()
+			resp, err := rs.getContainerStatus(c.Id)
+			container.Latency = rs.Clock.Since(now)
+			if grpcstatus.Code(err) == codes.NotFound {
+				rs.inconsistencies++
+				klog.Warningf("Container %s was listed but its status was not found", c.Id)
+				continue
+			}
 			if err != nil {
 				klog.Errorf("ContainerStatus for %s error: %v", c.Id, err)
 				continue
 			}
+			container.update(resp.Status)
+			rs.checkClockSkew(container, now.Add(container.Latency))
+			if verboseStatus() {
+				info, err := getContainerInfo(resp.Info)
+				if err != nil {
+					klog.Errorf("Parse verbose info of container %s error: %v", c.Id, err)
+				} else if info == nil {
+					klog.V(2).Infof("Runtime does not report verbose info for container %s", c.Id)
+				} else {
+					if inspectProcesses {
+						checkContainerProcess(c.Id, info.Pid)
+					}
+					if inspectCgroups {
+						checkContainerCgroup(c.Id, resp.Status.State == runtimeapi.ContainerState_CONTAINER_RUNNING, info)
+					}
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
-func (rs *runtimeService) getContainerStatus(containerID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+// callContext returns the context of a call, bounded by rs.Timeout.
+func (rs *runtimeService) callContext() (context.Context, context.CancelFunc) {
+	ctx := rs.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, rs.Timeout)
+}
+
+func (rs *runtimeService) getContainerStatus(containerID string) (*runtimeapi.ContainerStatusResponse, error) {
+	ctx, cancel := rs.callContext()
 	defer cancel()
 
 	resp, err := rs.Client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{
 		ContainerId: containerID,
+		Verbose:     verboseStatus(),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	status := resp.Status
-	klog.V(2).Infof("Container ID: %s, Status: %s, Message: %s, Reason: %s\n", status.Id, status.State.String(), status.Message, status.Reason)
-	klog.V(4).Infof("More Detail: %s\n", status.String())
+	// The arguments of disabled logs are still evaluated, which is the most
+	// of the allocations of a status call.
+	if klog.V(2) {
+		klog.Infof("Container ID: %s, Status: %s, Image: %s, Message: %s, Reason: %s\n", status.Id, colorState(status.State.String()), status.GetImage().GetImage(), status.Message, status.Reason)
+	}
+	if klog.V(4) {
+		klog.Infof("More Detail: %s\n", status.String())
+	}
 
-	return nil
+	return resp, nil
 }
 
-func (rs *runtimeService) getPodSandboxStatus(sandboxID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+func (rs *runtimeService) getPodSandboxStatus(sandboxID string) (*runtimeapi.PodSandboxStatusResponse, error) {
+	ctx, cancel := rs.callContext()
 	defer cancel()
 
 	resp, err := rs.Client.PodSandboxStatus(ctx, &runtimeapi.PodSandboxStatusRequest{
 		PodSandboxId: sandboxID,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	status := resp.Status
-	klog.V(2).Infof("Sandbox ID: %s, Status: %s\n", status.Id, status.State.String())
-	klog.V(4).Infof("More Detail: %s\n", status.String())
+	if klog.V(2) {
+		klog.Infof("Sandbox ID: %s, Status: %s\n", status.Id, colorState(status.State.String()))
+	}
+	if klog.V(4) {
+		klog.Infof("More Detail: %s\n", status.String())
+	}
 
-	return nil
+	return resp, nil
 }
 
-func (rs *runtimeService) getKubeletSandboxs(podUID string, all bool) ([]*runtimeapi.PodSandbox, error) {
+func kubeletSandboxFilter(podUID string, all bool) *runtimeapi.PodSandboxFilter {
 	var filter = &runtimeapi.PodSandboxFilter{}
 	if podUID != "" {
 		filter.LabelSelector = map[string]string{KubernetesPodUIDLabel: podUID}
@@ -235,8 +456,13 @@ func (rs *runtimeService) getKubeletSandboxs(podUID string, all bool) ([]*runtim
 			State: readyState,
 		}
 	}
+	return filter
+}
+
+func (rs *runtimeService) getKubeletSandboxs(podUID string, all bool) ([]*runtimeapi.PodSandbox, error) {
+	filter := kubeletSandboxFilter(podUID, all)
 
-	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	ctx, cancel := rs.callContext()
 	defer cancel()
 
 	resp, err := rs.Client.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{
@@ -250,7 +476,7 @@ func (rs *runtimeService) getKubeletSandboxs(podUID string, all bool) ([]*runtim
 	return resp.Items, nil
 }
 
-func (rs *runtimeService) getKubeletContainers(podUID string, all bool) ([]*runtimeapi.Container, error) {
+func kubeletContainerFilter(podUID string, all bool) *runtimeapi.ContainerFilter {
 	var filter = &runtimeapi.ContainerFilter{}
 
 	if podUID != "" {
@@ -261,8 +487,13 @@ func (rs *runtimeService) getKubeletContainers(podUID string, all bool) ([]*runt
 			State: runtimeapi.ContainerState_CONTAINER_RUNNING,
 		}
 	}
+	return filter
+}
+
+func (rs *runtimeService) getKubeletContainers(podUID string, all bool) ([]*runtimeapi.Container, error) {
+	filter := kubeletContainerFilter(podUID, all)
 
-	ctx, cancel := context.WithTimeout(context.Background(), rs.Timeout)
+	ctx, cancel := rs.callContext()
 	defer cancel()
 
 	resp, err := rs.Client.ListContainers(ctx, &runtimeapi.ListContainersRequest{
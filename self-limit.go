@@ -0,0 +1,12 @@
+package main
+
+var (
+	// niceness is the nice value of oncepleg, zero keeps the inherited one.
+	niceness = 0
+	// ioPriority is the IO scheduling of oncepleg, "idle" or "best-effort:<0-7>".
+	ioPriority = ""
+	// selfCgroup is a cgroup oncepleg moves itself into, relative to the cpu
+	// hierarchy, and selfCPUQuota its cpu limit in percent of one cpu.
+	selfCgroup   = ""
+	selfCPUQuota = 0
+)
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"github.com/coderwangke/oncepleg/internal/platform"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	ioprioClassShift  = 13
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	ioprioWhoProcess  = 1
+	cpuQuotaPeriodUs  = 100000
+	cgroupV1CPUSubdir = "cpu"
+)
+
+// setupSelfLimits lowers the priority of oncepleg and limits its cpu, so it
+// can run on production nodes under pressure.
+func setupSelfLimits() error {
+	if niceness != 0 || ioPriority != "" {
+		prio := 0
+		if ioPriority != "" {
+			var err error
+			if prio, err = parseIOPriority(ioPriority); err != nil {
+				return err
+			}
+		}
+		// The nice value and the io priority are per thread on Linux, set
+		// them on all of them. New threads inherit them.
+		tasks, err := ioutil.ReadDir(filepath.Join(procRoot, "self/task"))
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil {
+				continue
+			}
+			if niceness != 0 {
+				if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, niceness); err != nil {
+					return fmt.Errorf("set nice %d: %v", niceness, err)
+				}
+			}
+			if ioPriority != "" {
+				if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+					return fmt.Errorf("set io priority %s: %v", ioPriority, errno)
+				}
+			}
+		}
+		if niceness != 0 {
+			klog.V(2).Infof("Set nice to %d", niceness)
+		}
+		if ioPriority != "" {
+			klog.V(2).Infof("Set io priority to %s", ioPriority)
+		}
+	}
+
+	if selfCgroup != "" {
+		if err := joinSelfCgroup(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseIOPriority parses "idle" or "best-effort:<level>".
+func parseIOPriority(s string) (int, error) {
+	if s == "idle" {
+		return ioprioClassIdle << ioprioClassShift, nil
+	}
+	if strings.HasPrefix(s, "best-effort:") {
+		level, err := strconv.Atoi(strings.TrimPrefix(s, "best-effort:"))
+		if err == nil && level >= 0 && level <= 7 {
+			return ioprioClassBE<<ioprioClassShift | level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid io priority %q, want idle or best-effort:<0-7>", s)
+}
+
+// joinSelfCgroup creates selfCgroup with the cpu quota and moves oncepleg into it.
+func joinSelfCgroup() error {
+	dir := filepath.Join(cgroupRoot, selfCgroup)
+	quotaFile, quota := "cpu.max", "max"
+	if selfCPUQuota > 0 {
+		quota = strconv.Itoa(selfCPUQuota * cpuQuotaPeriodUs / 100)
+	}
+	quota = quota + " " + strconv.Itoa(cpuQuotaPeriodUs)
+	if !platform.IsCgroupV2(cgroupRoot) {
+		dir = filepath.Join(cgroupRoot, cgroupV1CPUSubdir, selfCgroup)
+		quotaFile, quota = "cpu.cfs_quota_us", "-1"
+		if selfCPUQuota > 0 {
+			quota = strconv.Itoa(selfCPUQuota * cpuQuotaPeriodUs / 100)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, quotaFile), []byte(quota), 0644); err != nil {
+		return fmt.Errorf("set cpu quota of cgroup %s: %v", dir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("join cgroup %s: %v", dir, err)
+	}
+	klog.V(2).Infof("Joined cgroup %s with cpu quota %s", dir, quota)
+	return nil
+}
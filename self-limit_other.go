@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "fmt"
+
+// setupSelfLimits is only supported on Linux.
+func setupSelfLimits() error {
+	if niceness != 0 || ioPriority != "" || selfCgroup != "" {
+		return fmt.Errorf("-nice, -io-priority and -self-cgroup are only supported on Linux")
+	}
+	return nil
+}
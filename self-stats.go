@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// selfStats is the overhead of oncepleg itself, to show the probe adds
+// negligible load to the node. Values that can not be read are set to -1.
+type selfStats struct {
+	// CPU is the percentage of one cpu used since the previous stats.
+	CPU        float64 `json:"cpu"`
+	CPUTimeNs  int64   `json:"cpuTimeNs"`
+	RSS        int64   `json:"rss"`
+	Goroutines int     `json:"goroutines"`
+	// GCPauseNs is the total stop-the-world pause of the garbage collector.
+	GCPauseNs int64  `json:"gcPauseNs"`
+	NumGC     uint32 `json:"numGC"`
+	// Allocs and AllocBytes are the heap allocations since the previous
+	// stats, which drive the garbage collections of a fast watch.
+	Allocs     uint64 `json:"allocs"`
+	AllocBytes uint64 `json:"allocBytes"`
+}
+
+var (
+	lastSelfCPUTime    time.Duration
+	lastSelfTime       time.Time
+	lastSelfMallocs    uint64
+	lastSelfTotalAlloc uint64
+)
+
+// startSelfStats starts measuring the cpu usage of this process at the start
+// of the session, the first stats cover the time since.
+func startSelfStats(c clock) {
+	lastSelfTime = c.Now()
+	if usage, err := processes.Usage(os.Getpid()); err == nil {
+		lastSelfCPUTime = usage.CPUTime
+	}
+}
+
+// takeSelfStats reads the resource usage of this process, the cpu usage
+// since the previous stats.
+func takeSelfStats(c clock) *selfStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	s := &selfStats{
+		CPU:        -1,
+		CPUTimeNs:  -1,
+		RSS:        -1,
+		Goroutines: runtime.NumGoroutine(),
+		GCPauseNs:  int64(mem.PauseTotalNs),
+		NumGC:      mem.NumGC,
+		Allocs:     mem.Mallocs - lastSelfMallocs,
+		AllocBytes: mem.TotalAlloc - lastSelfTotalAlloc,
+	}
+	lastSelfMallocs, lastSelfTotalAlloc = mem.Mallocs, mem.TotalAlloc
+	usage, err := processes.Usage(os.Getpid())
+	if err != nil {
+		return s
+	}
+	cpuTime := usage.CPUTime
+	s.CPUTimeNs, s.RSS = int64(cpuTime), usage.RSS
+	now := c.Now()
+	if elapsed := now.Sub(lastSelfTime); !lastSelfTime.IsZero() && elapsed > 0 {
+		s.CPU = float64(cpuTime-lastSelfCPUTime) * 100 / float64(elapsed)
+	}
+	lastSelfCPUTime, lastSelfTime = cpuTime, now
+	return s
+}
+
+func (s *selfStats) String() string {
+	return fmt.Sprintf("cpu %.1f%%, cpu time %v, rss %.1fMiB, goroutines %d, gc pause %v in %d cycles, allocated %.1fMiB in %d allocs",
+		s.CPU, time.Duration(s.CPUTimeNs), float64(s.RSS)/(1<<20), s.Goroutines, time.Duration(s.GCPauseNs), s.NumGC, float64(s.AllocBytes)/(1<<20), s.Allocs)
+}
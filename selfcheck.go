@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// privilegedOperation is an access the current configuration needs, to
+// write minimal security profiles for oncepleg.
+type privilegedOperation struct {
+	Operation string
+	Target    string
+	NeededBy  string
+	// check returns nil if the access is granted to the current process.
+	check func() error
+}
+
+// checkRead opens path for reading, listing it if it is a directory.
+func checkRead(path string) func() error {
+	return func() error {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			_, err = ioutil.ReadDir(path)
+			return err
+		}
+		f, err := os.Open(path)
+		if err == nil {
+			f.Close()
+		}
+		return err
+	}
+}
+
+// checkWrite creates and removes a file in dir.
+func checkWrite(dir string) func() error {
+	return func() error {
+		f, err := ioutil.TempFile(dir, ".oncepleg-selfcheck-")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+}
+
+func checkRoot() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("not root")
+	}
+	return nil
+}
+
+// privilegedOperations lists the accesses needed by the enabled features.
+func privilegedOperations() []*privilegedOperation {
+	ops := []*privilegedOperation{
+		{"connect", remoteRuntimeEndpoint, "CRI calls", func() error {
+			u, err := url.Parse(remoteRuntimeEndpoint)
+			if err != nil {
+				return err
+			}
+			conn, err := net.DialTimeout(unixProtocol, u.Path, time.Second)
+			if err == nil {
+				conn.Close()
+			}
+			return err
+		}},
+		{"read", filepath.Join(procRoot, "<runtime pid>"), "runtime daemon health", func() error {
+			d := findRuntimeDaemon(remoteRuntimeEndpoint, monotonicClock{})
+			if d == nil {
+				return fmt.Errorf("runtime daemon not found")
+			}
+			return checkRead(filepath.Join(procRoot, strconv.Itoa(d.Pid), "fd"))()
+		}},
+		{"read", filepath.Join(procRoot, "stat"), "host snapshot", checkRead(filepath.Join(procRoot, "stat"))},
+	}
+	if watchSocket {
+		if u, err := url.Parse(remoteRuntimeEndpoint); err == nil {
+			ops = append(ops, &privilegedOperation{"inotify", filepath.Dir(u.Path), "-watch-socket", checkRead(filepath.Dir(u.Path))})
+		}
+	}
+	if inspectProcesses {
+		ops = append(ops, &privilegedOperation{"read", filepath.Join(procRoot, "<container pid>"), "-inspect-processes", checkRead(filepath.Join(procRoot, "1", "task"))})
+	}
+	if inspectCgroups {
+		ops = append(ops, &privilegedOperation{"read", cgroupRoot, "-inspect-cgroups", checkRead(cgroupRoot)})
+	}
+	if inspectNamespaces {
+		ops = append(ops, &privilegedOperation{"readlink", filepath.Join(procRoot, "<sandbox pid>", "ns", "pid"), "-inspect-namespaces", func() error {
+			_, err := processes.Namespace(1, "pid")
+			return err
+		}})
+	}
+	if diskCheck {
+		for _, dir := range getDiskCheckDirs("") {
+			ops = append(ops, &privilegedOperation{"write", dir, "-disk-check", checkWrite(dir)})
+		}
+	}
+	if scanLogs {
+		ops = append(ops, &privilegedOperation{"read", podLogsDir, "-scan-logs", checkRead(podLogsDir)})
+	}
+	if checkPodDirs {
+		ops = append(ops, &privilegedOperation{"read", kubeletPodsDir, "-check-pod-dirs", checkRead(kubeletPodsDir)})
+	}
+	if checkRuntimeConfig && podCIDR == "" {
+		ops = append(ops, &privilegedOperation{"read", kubeletConfig, "-check-runtime-config", checkRead(kubeletConfig)})
+	}
+	if correlateJournal {
+		ops = append(ops, &privilegedOperation{"exec", "journalctl -u containerd", "-correlate-journal", func() error {
+			return exec.Command("journalctl", "-u", "containerd", "-n", "1", "--no-pager").Run()
+		}})
+	}
+	if oncePerBoot {
+		ops = append(ops, &privilegedOperation{"write", filepath.Dir(bootMarker), "-once-per-boot", checkWrite(filepath.Dir(bootMarker))})
+	}
+	if auditLog != "" {
+		ops = append(ops, &privilegedOperation{"write", filepath.Dir(auditLog), "-audit-log", checkWrite(filepath.Dir(auditLog))})
+	}
+	if selfCgroup != "" {
+		ops = append(ops, &privilegedOperation{"write", cgroupRoot, "-self-cgroup", checkRoot})
+	}
+	if niceness < 0 {
+		ops = append(ops, &privilegedOperation{"CAP_SYS_NICE", "nice " + strconv.Itoa(niceness), "-nice", checkRoot})
+	}
+	if runAsGroup >= 0 || runAsUser >= 0 {
+		ops = append(ops, &privilegedOperation{"CAP_SETUID, CAP_SETGID", "", "-run-as-group, -run-as-user", checkRoot})
+	}
+	if _, port, err := net.SplitHostPort(listenAddress); err == nil {
+		if p, _ := strconv.Atoi(port); p > 0 && p < 1024 {
+			ops = append(ops, &privilegedOperation{"CAP_NET_BIND_SERVICE", listenAddress, "-listen", checkRoot})
+		}
+	}
+	if len(onBreachExec) > 0 {
+		ops = append(ops, &privilegedOperation{"exec", strings.Join(onBreachExec, " "), "-on-breach-exec", nil})
+	}
+	if !readOnly {
+		ops = append(ops, &privilegedOperation{"mutating CRI calls", remoteRuntimeEndpoint, "-read-only=false", nil})
+	}
+	return ops
+}
+
+// selfcheck reports the privileged operations the current configuration
+// needs and whether the current process is allowed to do them.
+func selfcheck() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tTARGET\tNEEDED BY\tALLOWED")
+	for _, op := range privilegedOperations() {
+		allowed := "-"
+		if op.check != nil {
+			allowed = "yes"
+			if err := op.check(); err != nil {
+				allowed = "no: " + err.Error()
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", op.Operation, op.Target, op.NeededBy, allowed)
+	}
+	w.Flush()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// shellScript is a file of shell commands run by the shell command instead
+// of reading stdin.
+var shellScript = ""
+
+type scriptStep struct {
+	Line      int         `json:"line"`
+	Command   string      `json:"command"`
+	Result    shellResult `json:"result,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Latency   float64     `json:"latency"`
+	LatencyNs int64       `json:"latencyNs"`
+}
+
+type scriptReport struct {
+	SchemaVersion string        `json:"schemaVersion"`
+	Script        string        `json:"script"`
+	Time          string        `json:"time"`
+	DurationUnit  string        `json:"durationUnit"`
+	Steps         []*scriptStep `json:"steps"`
+	Failed        int           `json:"failed"`
+}
+
+// runScript runs the commands of a script one after another on one
+// connection, one per line with blank lines and # comments skipped. A failed
+// command does not stop the script, the script fails at the end. With
+// -output json the results of all commands make one report.
+func runScript(rs *runtimeService) error {
+	path := shellScript
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := &shell{rs: rs}
+	r := &scriptReport{
+		SchemaVersion: reportSchemaVersion,
+		Script:        path,
+		Time:          formatTime(rs.Clock.Now()),
+		DurationUnit:  durationUnit,
+		Steps:         []*scriptStep{},
+	}
+	in := bufio.NewScanner(f)
+	for n := 1; in.Scan(); n++ {
+		line := strings.TrimSpace(in.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "exit" || line == "quit" {
+			break
+		}
+		step := &scriptStep{Line: n, Command: line}
+		now := rs.Clock.Now()
+		step.Result, err = s.exec(line)
+		latency := rs.Clock.Since(now)
+		step.Latency, step.LatencyNs = durationValue(latency), int64(latency)
+		if err != nil {
+			step.Error = err.Error()
+			r.Failed++
+		}
+		r.Steps = append(r.Steps, step)
+		if output != "json" {
+			fmt.Printf("# %s:%d: %s (%s)\n", path, n, line, formatDuration(latency))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+			} else if step.Result != nil {
+				step.Result.writeTable(os.Stdout)
+			}
+			fmt.Println()
+		}
+	}
+	if err := in.Err(); err != nil {
+		return err
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if r.Failed > 0 {
+		return fmt.Errorf("%d of %d commands of %s failed", r.Failed, len(r.Steps), path)
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// shell runs ad-hoc queries against the runtime on one connection.
+type shell struct {
+	rs *runtimeService
+	// ids are the sandbox and container IDs of the last pods and ps, for
+	// expanding the prefixes given to status and stats.
+	ids []string
+	// sandboxes are the sandbox IDs of the last pods.
+	sandboxes map[string]bool
+}
+
+// shellResult is the answer of a shell command.
+type shellResult interface {
+	writeTable(out io.Writer)
+}
+
+type shellCommand struct {
+	usage string
+	run   func(s *shell, args []string) (shellResult, error)
+}
+
+var shellCommands = map[string]shellCommand{
+	"pods":   {"pods                 list the sandboxes", (*shell).pods},
+	"ps":     {"ps [pod-id]          list the containers, of a sandbox if given", (*shell).ps},
+	"status": {"status <id>          status of a container or sandbox", (*shell).status},
+	"stats":  {"stats <id>           resource usage of a container", (*shell).stats},
+	"images": {"images               list the images", (*shell).images},
+}
+
+// shellUsage lists the commands of the shell.
+func shellUsage(out io.Writer) {
+	var names []string
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n", shellCommands[name].usage)
+	}
+	fmt.Fprintln(out, "  help                 this help")
+	fmt.Fprintln(out, "  exit                 leave the shell")
+}
+
+// runShell reads commands from stdin until exit or EOF, errors of a command
+// are printed and do not end the shell.
+func runShell(rs *runtimeService) error {
+	s := &shell{rs: rs}
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "oncepleg> ")
+		if !in.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return in.Err()
+		}
+		line := strings.TrimSpace(in.Text())
+		switch line {
+		case "exit", "quit":
+			return nil
+		case "help":
+			shellUsage(os.Stdout)
+			continue
+		}
+		result, err := s.exec(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			continue
+		}
+		if result != nil {
+			result.writeTable(os.Stdout)
+		}
+	}
+}
+
+// exec runs one command line, an empty line does nothing.
+func (s *shell) exec(line string) (shellResult, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	command, ok := shellCommands[fields[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q, see help", fields[0])
+	}
+	return command.run(s, fields[1:])
+}
+
+// expand returns the listed ID starting with prefix, or prefix itself if
+// none or several do.
+func (s *shell) expand(prefix string) string {
+	match := ""
+	for _, id := range s.ids {
+		if strings.HasPrefix(id, prefix) {
+			if match != "" && match != id {
+				return prefix
+			}
+			match = id
+		}
+	}
+	if match == "" {
+		return prefix
+	}
+	return match
+}
+
+func (s *shell) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.rs.Timeout)
+}
+
+func shellTime(nanos int64) string {
+	if nanos == 0 {
+		return ""
+	}
+	return formatTime(time.Unix(0, nanos))
+}
+
+type shellPod struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type shellPods []*shellPod
+
+func (s *shell) pods(args []string) (shellResult, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.rs.Client.ListPodSandbox(ctx, &runtimeapi.ListPodSandboxRequest{Filter: kubeletSandboxFilter("", true)})
+	if err != nil {
+		return nil, err
+	}
+	s.ids, s.sandboxes = nil, make(map[string]bool)
+	pods := shellPods{}
+	for _, sb := range resp.Items {
+		pod := &shellPod{ID: sb.Id, State: sb.State.String(), CreatedAt: shellTime(sb.CreatedAt)}
+		if sb.Metadata != nil {
+			pod.Namespace, pod.Name = sb.Metadata.Namespace, sb.Metadata.Name
+		}
+		pods = append(pods, pod)
+		s.ids = append(s.ids, sb.Id)
+		s.sandboxes[sb.Id] = true
+	}
+	return pods, nil
+}
+
+func (pods shellPods) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POD ID\tNAMESPACE\tNAME\tSTATE\tCREATED")
+	for _, p := range pods {
+		fmt.Fprintf(w, "%.13s\t%s\t%s\t%s\t%s\n", p.ID, p.Namespace, p.Name, colorState(p.State), p.CreatedAt)
+	}
+	w.Flush()
+}
+
+type shellContainer struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	PodID     string `json:"podID"`
+	Pod       string `json:"pod"`
+	State     string `json:"state"`
+	Image     string `json:"image"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type shellContainers []*shellContainer
+
+func (s *shell) ps(args []string) (shellResult, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: ps [pod-id]")
+	}
+	filter := kubeletContainerFilter("", true)
+	if len(args) == 1 {
+		filter.PodSandboxId = s.expand(args[0])
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.rs.Client.ListContainers(ctx, &runtimeapi.ListContainersRequest{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	// Keep the sandboxes of the last pods, ps of a pod often follows it.
+	ids := s.ids
+	containers := shellContainers{}
+	for _, c := range resp.Containers {
+		container := &shellContainer{
+			ID:        c.Id,
+			PodID:     c.PodSandboxId,
+			State:     c.State.String(),
+			Image:     c.GetImage().GetImage(),
+			CreatedAt: shellTime(c.CreatedAt),
+		}
+		if c.Metadata != nil {
+			container.Name = c.Metadata.Name
+		}
+		if ns, name := c.Labels[KubernetesPodNamespaceLabel], c.Labels[KubernetesPodNameLabel]; name != "" {
+			container.Pod = ns + "/" + name
+		}
+		containers = append(containers, container)
+		ids = append(ids, c.Id)
+	}
+	s.ids = ids
+	return containers, nil
+}
+
+func (containers shellContainers) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER ID\tNAME\tPOD ID\tPOD\tSTATE\tIMAGE\tCREATED")
+	for _, c := range containers {
+		fmt.Fprintf(w, "%.13s\t%s\t%.13s\t%s\t%s\t%s\t%s\n", c.ID, c.Name, c.PodID, c.Pod, colorState(c.State), c.Image, c.CreatedAt)
+	}
+	w.Flush()
+}
+
+// shellStatus is the status of a container or, if there is no such
+// container, of a sandbox.
+type shellStatus struct {
+	Container *runtimeapi.ContainerStatus  `json:"container,omitempty"`
+	Sandbox   *runtimeapi.PodSandboxStatus `json:"sandbox,omitempty"`
+}
+
+func (s *shell) status(args []string) (shellResult, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: status <id>")
+	}
+	id := s.expand(args[0])
+	ctx, cancel := s.context()
+	defer cancel()
+	// Try a container first unless the last pods listed the ID.
+	if !s.sandboxes[id] {
+		resp, err := s.rs.Client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: id})
+		if err == nil {
+			return &shellStatus{Container: resp.Status}, nil
+		}
+		if grpcstatus.Code(err) != codes.NotFound {
+			return nil, err
+		}
+	}
+	sandbox, err := s.rs.Client.PodSandboxStatus(ctx, &runtimeapi.PodSandboxStatusRequest{PodSandboxId: id})
+	if err != nil {
+		return nil, err
+	}
+	return &shellStatus{Sandbox: sandbox.Status}, nil
+}
+
+func (status *shellStatus) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if c := status.Container; c != nil {
+		fmt.Fprintf(w, "Container\t%s\n", c.Id)
+		if c.Metadata != nil {
+			fmt.Fprintf(w, "Name\t%s\n", c.Metadata.Name)
+		}
+		fmt.Fprintf(w, "State\t%s\n", colorState(c.State.String()))
+		fmt.Fprintf(w, "Image\t%s\n", c.GetImage().GetImage())
+		fmt.Fprintf(w, "Created\t%s\n", shellTime(c.CreatedAt))
+		fmt.Fprintf(w, "Started\t%s\n", shellTime(c.StartedAt))
+		fmt.Fprintf(w, "Finished\t%s\n", shellTime(c.FinishedAt))
+		fmt.Fprintf(w, "Exit code\t%d\n", c.ExitCode)
+		fmt.Fprintf(w, "Reason\t%s\n", c.Reason)
+		fmt.Fprintf(w, "Message\t%s\n", c.Message)
+		fmt.Fprintf(w, "Log path\t%s\n", c.LogPath)
+	}
+	if sb := status.Sandbox; sb != nil {
+		fmt.Fprintf(w, "Sandbox\t%s\n", sb.Id)
+		if sb.Metadata != nil {
+			fmt.Fprintf(w, "Pod\t%s/%s\n", sb.Metadata.Namespace, sb.Metadata.Name)
+			fmt.Fprintf(w, "UID\t%s\n", sb.Metadata.Uid)
+			fmt.Fprintf(w, "Attempt\t%d\n", sb.Metadata.Attempt)
+		}
+		fmt.Fprintf(w, "State\t%s\n", colorState(sb.State.String()))
+		fmt.Fprintf(w, "Created\t%s\n", shellTime(sb.CreatedAt))
+		if sb.Network != nil {
+			fmt.Fprintf(w, "IP\t%s\n", sb.Network.Ip)
+		}
+	}
+	w.Flush()
+}
+
+type shellStats struct {
+	ID string `json:"id"`
+	// CPU is the cumulative CPU usage in core nanoseconds.
+	CPU                 uint64 `json:"cpu"`
+	MemoryWorkingSet    uint64 `json:"memoryWorkingSet"`
+	WritableLayer       uint64 `json:"writableLayer"`
+	WritableLayerInodes uint64 `json:"writableLayerInodes"`
+	Time                string `json:"time"`
+}
+
+func (s *shell) stats(args []string) (shellResult, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: stats <id>")
+	}
+	ctx, cancel := s.context()
+	defer cancel()
+	resp, err := s.rs.Client.ContainerStats(ctx, &runtimeapi.ContainerStatsRequest{ContainerId: s.expand(args[0])})
+	if err != nil {
+		return nil, err
+	}
+	st := resp.Stats
+	stats := &shellStats{ID: st.GetAttributes().GetId()}
+	if cpu := st.Cpu; cpu != nil {
+		stats.CPU = cpu.GetUsageCoreNanoSeconds().GetValue()
+		stats.Time = shellTime(cpu.Timestamp)
+	}
+	if mem := st.Memory; mem != nil {
+		stats.MemoryWorkingSet = mem.GetWorkingSetBytes().GetValue()
+	}
+	if fs := st.WritableLayer; fs != nil {
+		stats.WritableLayer = fs.GetUsedBytes().GetValue()
+		stats.WritableLayerInodes = fs.GetInodesUsed().GetValue()
+	}
+	return stats, nil
+}
+
+func (stats *shellStats) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Container\t%s\n", stats.ID)
+	fmt.Fprintf(w, "CPU\t%v\n", time.Duration(stats.CPU))
+	fmt.Fprintf(w, "Memory working set\t%.1fMiB\n", float64(stats.MemoryWorkingSet)/(1<<20))
+	fmt.Fprintf(w, "Writable layer\t%.1fMiB, %d inodes\n", float64(stats.WritableLayer)/(1<<20), stats.WritableLayerInodes)
+	fmt.Fprintf(w, "Time\t%s\n", stats.Time)
+	w.Flush()
+}
+
+type shellImage struct {
+	ID   string   `json:"id"`
+	Tags []string `json:"tags"`
+	Size uint64   `json:"size"`
+}
+
+type shellImages []*shellImage
+
+func (s *shell) images(args []string) (shellResult, error) {
+	images, _, err := s.rs.listImages()
+	if err != nil {
+		return nil, err
+	}
+	result := shellImages{}
+	for _, img := range images {
+		result = append(result, &shellImage{ID: img.Id, Tags: img.RepoTags, Size: img.Size_})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Size > result[j].Size })
+	return result, nil
+}
+
+func (images shellImages) writeTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE ID\tTAGS\tSIZE")
+	for _, i := range images {
+		tags := "<none>"
+		if len(i.Tags) > 0 {
+			tags = strings.Join(i.Tags, ",")
+		}
+		fmt.Fprintf(w, "%.13s\t%s\t%.1fMiB\n", strings.TrimPrefix(i.ID, "sha256:"), tags, float64(i.Size)/(1<<20))
+	}
+	w.Flush()
+}
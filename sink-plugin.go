@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sink plugins are executables receiving every report of -output plugin=name.
+// The plugin protocol, version pluginProtocolVersion:
+//   - the JSON report is written to stdin, the schema of -schema
+//   - ONCEPLEG_PLUGIN_PROTOCOL, ONCEPLEG_SCHEMA_VERSION and ONCEPLEG_NODE are set
+//   - exit code 0 means delivered, pluginUnavailable that the destination is
+//     temporarily unavailable, anything else that the report was rejected
+//   - stderr is logged when the plugin does not exit with 0
+const (
+	pluginProtocolVersion = "1"
+	pluginUnavailable     = 75
+)
+
+var (
+	// pluginDir is searched for the plugins of -output plugin=name.
+	pluginDir     = filepath.Join(os.Getenv("HOME"), ".oncepleg", "plugins")
+	pluginTimeout = 30 * time.Second
+)
+
+// pluginPath resolves the executable of a plugin, names with a slash are paths.
+func pluginPath(name string) string {
+	if strings.ContainsRune(name, filepath.Separator) {
+		return name
+	}
+	return filepath.Join(pluginDir, name)
+}
+
+// runPlugin hands a relist to a sink plugin.
+func runPlugin(c clock, name string, result *relistResult) error {
+	report, err := json.Marshal(newReport(result))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pluginPath(name))
+	cmd.Stdin = bytes.NewReader(report)
+	cmd.Stdout = ioutil.Discard
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"ONCEPLEG_PLUGIN_PROTOCOL="+pluginProtocolVersion,
+		"ONCEPLEG_SCHEMA_VERSION="+reportSchemaVersion,
+		"ONCEPLEG_NODE="+nodeName,
+	)
+	now := c.Now()
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == pluginUnavailable {
+		klog.Warningf("Sink plugin %s is unavailable: %s", name, strings.TrimSpace(stderr.String()))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%v, stderr: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	klog.V(4).Infof("Sink plugin %s finished in %v", name, c.Since(now))
+	return nil
+}
+
+// listPlugins prints the executables of pluginDir.
+func listPlugins() error {
+	entries, err := ioutil.ReadDir(pluginDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && entry.Mode()&0111 != 0 {
+			fmt.Println(entry.Name())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// outputMaxBytes rotates the output files once they grow over it, zero never rotates.
+	outputMaxBytes = int64(0)
+	// outputMaxFiles is the number of rotated files kept of every output file.
+	outputMaxFiles = 5
+	// outputGzip compresses the rotated output files.
+	outputGzip = false
+)
+
+// rotatedFile is an entry of the manifest of an output file.
+type rotatedFile struct {
+	File string `json:"file"`
+	// From and To are the times of the first and last reports of the file,
+	// From is unknown for a file created by a previous process.
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Reports int    `json:"reports"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// outputManifest indexes the rotated files of an output file, oldest first.
+type outputManifest struct {
+	Files []*rotatedFile `json:"files"`
+}
+
+func manifestPath(path string) string {
+	return path + ".manifest.json"
+}
+
+func readManifest(path string) *outputManifest {
+	m := &outputManifest{}
+	data, err := ioutil.ReadFile(manifestPath(path))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		klog.Warningf("Ignore malformed manifest %s: %v", manifestPath(path), err)
+	}
+	return m
+}
+
+// writeManifest replaces the manifest atomically, readers never see it half written.
+func writeManifest(path string, m *outputManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := manifestPath(path) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, manifestPath(path))
+}
+
+// rotate moves the output file aside once it is over outputMaxBytes at now,
+// compresses it with -output-gzip and removes the oldest rotated files over
+// outputMaxFiles.
+func (s *outputSink) rotate(now time.Time) error {
+	info, err := s.stat()
+	if err != nil || outputMaxBytes <= 0 || info.Size() < outputMaxBytes {
+		return err
+	}
+
+	rotated := s.path + "." + now.UTC().Format("20060102T150405.000Z")
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+	// The file opened ahead is the rotated one now.
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if outputGzip {
+		if err := gzipFile(rotated); err != nil {
+			klog.Errorf("Compress %s failed: %v", rotated, err)
+		} else {
+			rotated += ".gz"
+		}
+	}
+	entry := &rotatedFile{File: filepath.Base(rotated), From: formatOptionalTime(s.from), To: formatOptionalTime(s.to), Reports: s.reports, Bytes: info.Size()}
+	if info, err := os.Stat(rotated); err == nil {
+		entry.Bytes = info.Size()
+	}
+	s.from, s.to, s.reports = time.Time{}, time.Time{}, 0
+
+	m := readManifest(s.path)
+	m.Files = append(m.Files, entry)
+	for len(m.Files) > outputMaxFiles {
+		if err := os.Remove(filepath.Join(filepath.Dir(s.path), m.Files[0].File)); err != nil && !os.IsNotExist(err) {
+			klog.Errorf("Remove rotated output %s failed: %v", m.Files[0].File, err)
+		}
+		m.Files = m.Files[1:]
+	}
+	klog.V(2).Infof("Rotated output %s to %s", s.path, rotated)
+	return writeManifest(s.path, m)
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// sinkTestAddress is the default address of the sink-test command.
+const sinkTestAddress = ":8080"
+
+// sinkTest receives and prints whatever the webhook, Loki and other HTTP
+// outputs send, to validate payload templates before using real receivers.
+func sinkTest(addr string) error {
+	if addr == "" {
+		addr = sinkTestAddress
+	}
+	var mu sync.Mutex
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		printRequest(r, body)
+		w.WriteHeader(http.StatusOK)
+	})
+	klog.Infof("Printing the requests received on %s", addr)
+	return http.ListenAndServe(addr, handler)
+}
+
+func printRequest(r *http.Request, body []byte) {
+	fmt.Printf("--- %s %s %s from %s\n", formatTime(time.Now()), r.Method, r.URL.RequestURI(), r.RemoteAddr)
+	var names []string
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			fmt.Printf("%s: %s\n", name, value)
+		}
+	}
+	fmt.Println()
+
+	var pretty bytes.Buffer
+	err := json.Indent(&pretty, body, "", "  ")
+	switch {
+	case err == nil:
+		pretty.WriteTo(os.Stdout)
+		fmt.Println()
+	case r.Header.Get("Content-Type") == "application/json":
+		// A broken template is the usual cause.
+		fmt.Printf("%s\n", body)
+		klog.Warningf("Body is not valid JSON: %v", err)
+	case len(body) > 0:
+		fmt.Printf("%s\n", body)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// outputSink is a destination of the report, stdout if path is empty.
+type outputSink struct {
+	format string
+	path   string
+
+	// from and to are the times of the first and last reports written to the
+	// file since it was opened or rotated, reports counts them.
+	from    time.Time
+	to      time.Time
+	reports int
+	// file is opened ahead of the reports before dropping privileges, nil
+	// if the file is opened for every report.
+	file *os.File
+}
+
+// outputSinks are the destinations given with -output, the report goes to
+// stdout in the output format if there are none.
+var outputSinks []*outputSink
+
+// outputsFlag is the repeatable -output flag, "format" writes to stdout and
+// "format=path" appends to a file, except "loki=url" pushing to Loki and
+// "plugin=name" running a sink plugin.
+type outputsFlag struct{}
+
+func (outputsFlag) String() string {
+	if len(outputSinks) == 0 {
+		return output
+	}
+	var values []string
+	for _, s := range outputSinks {
+		if s.path == "" {
+			values = append(values, s.format)
+		} else {
+			values = append(values, s.format+"="+s.path)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func (outputsFlag) Set(value string) error {
+	format, path := value, ""
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		format, path = value[:i], value[i+1:]
+	}
+	if path == "-" {
+		path = ""
+	}
+	switch {
+	case format == "loki" && path == "":
+		return fmt.Errorf("the loki output needs the URL of Loki, e.g. loki=http://loki:3100")
+	case format == "plugin" && path == "":
+		return fmt.Errorf("the plugin output needs the name of the plugin, e.g. plugin=jira")
+	case format != "table" && format != "json" && format != "tree" && format != "loki" && format != "plugin":
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	// The commands printing something else than relists follow the stdout format.
+	if path == "" {
+		output = format
+	}
+	outputSinks = append(outputSinks, &outputSink{format: format, path: path})
+	return nil
+}
+
+func (s *outputSink) write(c clock, result *relistResult) error {
+	switch s.format {
+	case "loki":
+		return pushLoki(s.path, result)
+	case "plugin":
+		return runPlugin(c, s.path, result)
+	}
+	if s.path == "" {
+		// JSON reports are written one per line when watching.
+		return writeResult(os.Stdout, s.format, watchInterval <= 0, result)
+	}
+	if s.reports == 0 {
+		// A file left by a previous process has reports from before ours.
+		if info, err := s.stat(); err != nil || info.Size() == 0 {
+			s.from = result.Time
+		}
+	}
+	f := s.file
+	if f == nil {
+		var err error
+		if f, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+			return err
+		}
+	}
+	err := writeResult(f, s.format, false, result)
+	if f != s.file {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	s.to = result.Time
+	s.reports++
+	return s.rotate(result.Time)
+}
+
+// open opens the file of the sink ahead of its reports.
+func (s *outputSink) open() error {
+	if s.path == "" || s.format == "loki" || s.format == "plugin" {
+		return nil
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	return nil
+}
+
+// stat returns the file of the sink, through the file opened ahead if any
+// as its directory may not be accessible anymore.
+func (s *outputSink) stat() (os.FileInfo, error) {
+	if s.file != nil {
+		return s.file.Stat()
+	}
+	return os.Stat(s.path)
+}
+
+// writeResults writes the relist to every sink, a failing sink does not keep
+// the report from the others.
+func writeResults(c clock, result *relistResult) error {
+	if len(outputSinks) == 0 {
+		return (&outputSink{format: output}).write(c, result)
+	}
+	var errs []string
+	for _, s := range outputSinks {
+		if err := s.write(c, result); err != nil {
+			errs = append(errs, fmt.Sprintf("%s=%s: %v", s.format, s.path, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("write report failed: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	runtimeapi "github.com/kubernetes/cri-api/pkg/apis/runtime/v1alpha2"
+	"k8s.io/klog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// smokeImage is the image of the smoke test container.
+var smokeImage = "k8s.gcr.io/pause:3.2"
+
+// smokeStep is one timed mutation of the smoke test.
+type smokeStep struct {
+	name    string
+	latency time.Duration
+	err     error
+}
+
+// smokeTest creates a sandbox and a container, starts, stops and removes
+// them, timing every call to verify the write path of the runtime.
+type smokeTest struct {
+	rs    *runtimeService
+	steps []*smokeStep
+}
+
+// step times call and records its outcome.
+func (t *smokeTest) step(name string, call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), t.rs.Timeout)
+	defer cancel()
+	now := t.rs.Clock.Now()
+	err := call(ctx)
+	t.steps = append(t.steps, &smokeStep{name: name, latency: t.rs.Clock.Since(now), err: err})
+	if err != nil {
+		klog.Errorf("Smoke test %s failed: %v", name, err)
+	}
+	return err
+}
+
+func smoke(rs *runtimeService) error {
+	if readOnly {
+		return fmt.Errorf("the smoke command creates and removes a sandbox and a container, set -read-only=false to run it")
+	}
+	if blocked := activeChecksBlocked(rs.Clock.Now(), takeHostSnapshot(readCPUTimes()).Load1); blocked != "" {
+		return fmt.Errorf("the smoke command is skipped: %s", blocked)
+	}
+	t := &smokeTest{rs: rs}
+	err := t.run()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tLATENCY\tRESULT")
+	for _, s := range t.steps {
+		result := "ok"
+		if s.err != nil {
+			result = s.err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.name, formatDuration(s.latency), result)
+	}
+	w.Flush()
+	return err
+}
+
+func (t *smokeTest) run() error {
+	rs := t.rs
+	name := fmt.Sprintf("oncepleg-smoke-%d", time.Now().Unix())
+	image := &runtimeapi.ImageSpec{Image: smokeImage}
+	labels := map[string]string{"oncepleg.smoke": "true"}
+
+	pulled := false
+	err := t.step("ImageStatus", func(ctx context.Context) error {
+		resp, err := rs.Images.ImageStatus(ctx, &runtimeapi.ImageStatusRequest{Image: image})
+		pulled = err == nil && resp.Image != nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !pulled {
+		if err := t.step("PullImage", func(ctx context.Context) error {
+			_, err := rs.Images.PullImage(ctx, &runtimeapi.PullImageRequest{Image: image})
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	sandboxConfig := &runtimeapi.PodSandboxConfig{
+		Metadata: &runtimeapi.PodSandboxMetadata{Name: name, Namespace: "oncepleg", Uid: name},
+		Labels:   labels,
+	}
+	var sandboxID string
+	err = t.step("RunPodSandbox", func(ctx context.Context) error {
+		resp, err := rs.Client.RunPodSandbox(ctx, &runtimeapi.RunPodSandboxRequest{Config: sandboxConfig})
+		if err == nil {
+			sandboxID = resp.PodSandboxId
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Always clean up the sandbox, even if a container step failed.
+	defer func() {
+		t.step("StopPodSandbox", func(ctx context.Context) error {
+			_, err := rs.Client.StopPodSandbox(ctx, &runtimeapi.StopPodSandboxRequest{PodSandboxId: sandboxID})
+			return err
+		})
+		t.step("RemovePodSandbox", func(ctx context.Context) error {
+			_, err := rs.Client.RemovePodSandbox(ctx, &runtimeapi.RemovePodSandboxRequest{PodSandboxId: sandboxID})
+			return err
+		})
+	}()
+
+	var containerID string
+	err = t.step("CreateContainer", func(ctx context.Context) error {
+		resp, err := rs.Client.CreateContainer(ctx, &runtimeapi.CreateContainerRequest{
+			PodSandboxId: sandboxID,
+			Config: &runtimeapi.ContainerConfig{
+				Metadata: &runtimeapi.ContainerMetadata{Name: "smoke"},
+				Image:    image,
+				Labels:   labels,
+			},
+			SandboxConfig: sandboxConfig,
+		})
+		if err == nil {
+			containerID = resp.ContainerId
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer t.step("RemoveContainer", func(ctx context.Context) error {
+		_, err := rs.Client.RemoveContainer(ctx, &runtimeapi.RemoveContainerRequest{ContainerId: containerID})
+		return err
+	})
+
+	if err := t.step("StartContainer", func(ctx context.Context) error {
+		_, err := rs.Client.StartContainer(ctx, &runtimeapi.StartContainerRequest{ContainerId: containerID})
+		return err
+	}); err != nil {
+		return err
+	}
+	return t.step("StopContainer", func(ctx context.Context) error {
+		_, err := rs.Client.StopContainer(ctx, &runtimeapi.StopContainerRequest{ContainerId: containerID, Timeout: 10})
+		return err
+	})
+}
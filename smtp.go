@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/klog"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	smtpServer       = ""
+	smtpFrom         = "oncepleg@localhost"
+	smtpTo           = ""
+	smtpUsername     = ""
+	smtpPasswordFile = ""
+	smtpSubject      = "[oncepleg] {{.Node}}: {{.Check}} {{if eq .State \"firing\"}}breached threshold {{.Threshold}}{{else}}recovered{{end}}"
+	// smtpMinInterval rate limits the repeated firing emails of a check during
+	// sustained degradation, its recovery is always sent.
+	smtpMinInterval = 30 * time.Minute
+)
+
+type smtpNotifier struct {
+	server  string
+	from    string
+	to      []string
+	auth    smtp.Auth
+	subject *template.Template
+
+	// lastFiring is when the firing email of every check still firing was
+	// last sent, suppressed counts the ones suppressed since.
+	lastFiring map[string]time.Time
+	suppressed map[string]int
+}
+
+func newSMTPNotifier() (*smtpNotifier, error) {
+	if smtpTo == "" {
+		return nil, fmt.Errorf("no recipients for the smtp server %s", smtpServer)
+	}
+	subject, err := template.New("subject").Parse(smtpSubject)
+	if err != nil {
+		return nil, err
+	}
+	n := &smtpNotifier{
+		server:  smtpServer,
+		from:    smtpFrom,
+		to:      strings.Split(smtpTo, ","),
+		subject: subject,
+
+		lastFiring: make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+	if smtpUsername != "" {
+		password, err := ioutil.ReadFile(smtpPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		host, _, err := net.SplitHostPort(smtpServer)
+		if err != nil {
+			return nil, err
+		}
+		n.auth = smtp.PlainAuth("", smtpUsername, strings.TrimSpace(string(password)), host)
+	}
+	return n, nil
+}
+
+func (n *smtpNotifier) notify(a *alert) error {
+	lastSent, repeated := n.lastFiring[a.Check]
+	if a.State == alertFiring && repeated && a.Time.Sub(lastSent) < smtpMinInterval {
+		n.suppressed[a.Check]++
+		klog.V(2).Infof("Email of %s suppressed, last one was sent at %s", a.Check, lastSent.Format(time.RFC3339))
+		return nil
+	}
+
+	var subject bytes.Buffer
+	if err := n.subject.Execute(&subject, a); err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.Replace(subject.String(), "\n", " ", -1))
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Node: %s\r\nCheck: %s\r\nState: %s\r\nTime: %s\r\n\r\n%s\r\n", a.Node, a.Check, a.State, a.Time.Format(time.RFC3339), a.Summary())
+	if suppressed := n.suppressed[a.Check]; suppressed > 0 {
+		fmt.Fprintf(&msg, "\r\n%d notifications were suppressed since %s.\r\n", suppressed, lastSent.Format(time.RFC3339))
+	}
+
+	if err := smtp.SendMail(n.server, n.auth, n.from, n.to, msg.Bytes()); err != nil {
+		return err
+	}
+	if a.State == alertFiring {
+		n.lastFiring[a.Check] = a.Time
+	} else {
+		delete(n.lastFiring, a.Check)
+	}
+	delete(n.suppressed, a.Check)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"time"
+)
+
+var (
+	// watchSocket reconnects to the runtime when its socket is replaced during -watch.
+	watchSocket = false
+	// socketReplaced receives the time the socket of the runtime was replaced.
+	socketReplaced = make(chan time.Time, 1)
+)
+
+// notifySocketReplaced is called by the socket watch, only the latest
+// replacement since the previous relist matters.
+func notifySocketReplaced(t time.Time) {
+	select {
+	case <-socketReplaced:
+	default:
+	}
+	socketReplaced <- t
+}
+
+// checkSocketReplaced reconnects to the runtime if its socket was replaced
+// since the previous relist, the old connection would only notice after the
+// gRPC reconnect backoff.
+func (rs *runtimeService) checkSocketReplaced() {
+	select {
+	case t := <-socketReplaced:
+		recordRuntimeRestart(t, "socket replaced")
+		if err := rs.reconnect(); err != nil {
+			klog.Errorf("Reconnect to %s failed: %v", remoteRuntimeEndpoint, err)
+		}
+	default:
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"k8s.io/klog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// startSocketWatch watches the directory of the runtime socket with inotify,
+// a runtime restart or upgrade creates a new socket inode at the path.
+func startSocketWatch(endpoint string) error {
+	addr, _, err := getAddressAndDialer(endpoint)
+	if err != nil {
+		return err
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(addr), syscall.IN_CREATE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 4096)
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				klog.Errorf("Watch runtime socket %s failed: %v", addr, err)
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(event.Len)]
+				off += syscall.SizeofInotifyEvent + int(event.Len)
+				if strings.TrimRight(string(name), "\x00") != filepath.Base(addr) {
+					continue
+				}
+				// Inode numbers are reused by some filesystems, so every
+				// socket created at the path counts as a replacement.
+				klog.V(2).Infof("Runtime socket %s replaced", addr)
+				notifySocketReplaced(time.Now())
+			}
+		}
+	}()
+	return nil
+}
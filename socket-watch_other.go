@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "fmt"
+
+func startSocketWatch(string) error {
+	return fmt.Errorf("watching the runtime socket is only supported on Linux")
+}
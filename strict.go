@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"k8s.io/klog"
+)
+
+var (
+	// strict treats sandboxes without metadata and containers without the
+	// kubelet labels as conformance violations.
+	strict = false
+	// strictExitCode is the exit code of a relist having violations in strict mode.
+	strictExitCode = 3
+)
+
+// kubeletContainerLabels are the labels the kubelet sets on every container.
+var kubeletContainerLabels = []string{
+	KubernetesPodNameLabel,
+	KubernetesPodNamespaceLabel,
+	KubernetesPodUIDLabel,
+	KubernetesContainerNameLabel,
+}
+
+// violationError is returned by a relist having conformance violations in strict mode.
+type violationError struct {
+	violations int
+}
+
+func (e *violationError) Error() string {
+	return fmt.Sprintf("%d conformance violations in strict mode", e.violations)
+}
+
+// violation counts and reports a conformance violation in strict mode.
+func (rs *runtimeService) violation(format string, args ...interface{}) {
+	if !strict {
+		return
+	}
+	rs.violations++
+	klog.Warningf(format, args...)
+}
+
+// missingContainerLabels returns the kubelet labels missing on a container.
+func missingContainerLabels(labels map[string]string) []string {
+	var missing []string
+	for _, label := range kubeletContainerLabels {
+		if _, ok := labels[label]; !ok {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
+
+// checkContainerLabels reports the kubelet labels missing on a container.
+func (rs *runtimeService) checkContainerLabels(id string, missing []string) {
+	for _, label := range missing {
+		rs.violation("Container %s does not have label %s", id, label)
+	}
+}
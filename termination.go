@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// terminationMessageLimit is the size kubelet keeps of a termination message.
+const terminationMessageLimit = 4096
+
+var (
+	// terminationLog receives the summary of the last relist on exit, so that
+	// kubectl describe pod shows it. Only written if it exists, as kubelet
+	// creates it in containers.
+	terminationLog = "/dev/termination-log"
+	// terminationFile is terminationLog opened before dropping privileges.
+	terminationFile *os.File
+
+	// breaches are the breached alerts of the current relist.
+	breaches []*alert
+)
+
+type terminationBreach struct {
+	Check     string `json:"check"`
+	Severity  string `json:"severity,omitempty"`
+	Duration  string `json:"duration"`
+	Threshold string `json:"threshold"`
+	Error     string `json:"error,omitempty"`
+}
+
+type terminationRPC struct {
+	Calls  int    `json:"calls"`
+	Errors int    `json:"errors"`
+	Max    string `json:"max"`
+}
+
+// terminationMessage is the summary of the last relist, small enough for kubelet.
+type terminationMessage struct {
+	Time     string               `json:"time"`
+	Node     string               `json:"node"`
+	Error    string               `json:"error,omitempty"`
+	Relist   string               `json:"relist,omitempty"`
+	Pods     int                  `json:"pods"`
+	Breaches []*terminationBreach `json:"breaches"`
+	// Truncated is the number of breaches dropped to fit the limit.
+	Truncated int                        `json:"truncated,omitempty"`
+	RPCs      map[string]*terminationRPC `json:"rpcs,omitempty"`
+}
+
+// writeTerminationMessage writes the summary of the last relist and err at now to terminationLog.
+func writeTerminationMessage(now time.Time, err error) error {
+	if terminationLog == "" {
+		return nil
+	}
+	if terminationFile == nil {
+		if _, statErr := os.Stat(terminationLog); statErr != nil {
+			return nil
+		}
+	}
+
+	m := &terminationMessage{Time: formatTime(now), Node: nodeName, Breaches: []*terminationBreach{}}
+	if err != nil {
+		m.Error = err.Error()
+	}
+	lastResultMu.Lock()
+	result := lastResult
+	lastResultMu.Unlock()
+	if result != nil {
+		m.Relist, m.Pods = result.Duration.String(), len(result.Pods)
+		m.RPCs = make(map[string]*terminationRPC, len(result.RPCs))
+		for method, s := range result.RPCs {
+			errors := s.Errors
+			if s.Hedged != nil {
+				errors += s.Hedged.Errors
+			}
+			m.RPCs[method] = &terminationRPC{Calls: s.AllCalls(), Errors: errors, Max: s.Slowest().String()}
+		}
+	}
+	for _, a := range breaches {
+		m.Breaches = append(m.Breaches, &terminationBreach{
+			Check:     a.Check,
+			Severity:  a.Severity,
+			Duration:  a.Duration.String(),
+			Threshold: a.Threshold.String(),
+			Error:     a.Error,
+		})
+	}
+
+	data, jsonErr := fitTerminationMessage(m)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	if terminationFile != nil {
+		return rewriteFile(terminationFile, data)
+	}
+	return ioutil.WriteFile(terminationLog, data, 0644)
+}
+
+// fitTerminationMessage marshals m within terminationMessageLimit, so that it
+// stays valid JSON. The breaches matter most, the latencies of every method
+// are dropped first, then the last breaches and finally the end of the error.
+func fitTerminationMessage(m *terminationMessage) ([]byte, error) {
+	for {
+		data, err := json.Marshal(m)
+		if err != nil || len(data) <= terminationMessageLimit {
+			return data, err
+		}
+		excess := len(data) - terminationMessageLimit
+		switch {
+		case m.RPCs != nil:
+			m.RPCs = nil
+		case len(m.Breaches) > 0:
+			m.Breaches = m.Breaches[:len(m.Breaches)-1]
+			m.Truncated++
+		case len(m.Error) > 0:
+			// Escaping may make the error longer than its bytes, cut by at
+			// least the excess until it fits.
+			cut := len(m.Error) - excess - len("...")
+			if cut < 0 {
+				cut = 0
+			}
+			m.Error = strings.ToValidUTF8(m.Error[:cut], "") + "..."
+			if cut == 0 {
+				m.Error = ""
+			}
+		default:
+			return data, nil
+		}
+	}
+}
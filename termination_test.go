@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTerminationMessageFitsLimit(t *testing.T) {
+	m := &terminationMessage{Node: "node", RPCs: map[string]*terminationRPC{"ListContainers": {Calls: 1}}}
+	for i := 0; i < 200; i++ {
+		m.Breaches = append(m.Breaches, &terminationBreach{Check: "ContainerStatus", Duration: "3s", Threshold: "1s", Error: "context deadline exceeded"})
+	}
+	m.Error = strings.Repeat("\"é", 3000)
+
+	data, err := fitTerminationMessage(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > terminationMessageLimit {
+		t.Fatalf("message of %d bytes, limit is %d", len(data), terminationMessageLimit)
+	}
+	var parsed terminationMessage
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("message is not valid JSON: %v", err)
+	}
+	if parsed.Truncated != 200 || len(parsed.Breaches) != 0 || parsed.RPCs != nil {
+		t.Errorf("truncated %d, %d breaches left, rpcs %v", parsed.Truncated, len(parsed.Breaches), parsed.RPCs)
+	}
+
+	m = &terminationMessage{Node: "node"}
+	for i := 0; i < 200; i++ {
+		m.Breaches = append(m.Breaches, &terminationBreach{Check: "relist", Duration: "3s", Threshold: "1s"})
+	}
+	data, _ = fitTerminationMessage(m)
+	parsed = terminationMessage{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("message is not valid JSON: %v", err)
+	}
+	if len(parsed.Breaches) == 0 || len(parsed.Breaches)+parsed.Truncated != 200 {
+		t.Errorf("%d breaches kept and %d truncated of 200", len(parsed.Breaches), parsed.Truncated)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// methodThreshold is the latency SLO of one CRI method.
+type methodThreshold struct {
+	Threshold time.Duration
+	Severity  string
+}
+
+var (
+	// methodThresholds are the SLOs of the CRI methods by name, e.g. "ListContainers".
+	methodThresholds = make(map[string]*methodThreshold)
+	// relistSeverity is the severity of a slow relist, failed relists are critical.
+	relistSeverity = "warning"
+)
+
+// severities are the severities of PagerDuty, which the other notifiers pass on.
+var severities = []string{"critical", "error", "warning", "info"}
+
+// thresholdsFlag parses -thresholds, a list of method=duration[:severity],
+// where the method "relist" sets the relist threshold.
+type thresholdsFlag struct{}
+
+func (thresholdsFlag) String() string {
+	var values []string
+	for method, t := range methodThresholds {
+		values = append(values, fmt.Sprintf("%s=%v:%s", method, t.Threshold, t.Severity))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+func (thresholdsFlag) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("threshold %q is not method=duration[:severity]", entry)
+		}
+		method, spec := strings.TrimSpace(kv[0]), kv[1]
+		severity := "warning"
+		if i := strings.IndexByte(spec, ':'); i >= 0 {
+			spec, severity = spec[:i], spec[i+1:]
+			if !contains(severities, severity) {
+				return fmt.Errorf("unknown severity %q of %s, one of %s", severity, method, strings.Join(severities, ", "))
+			}
+		}
+		d, err := time.ParseDuration(spec)
+		if err != nil {
+			return fmt.Errorf("threshold of %s: %v", method, err)
+		}
+		if method == "relist" {
+			relistThreshold, relistSeverity = d, severity
+			continue
+		}
+		methodThresholds[method] = &methodThreshold{Threshold: d, Severity: severity}
+	}
+	return nil
+}
+
+// notifyMethods checks the slowest call of every method having a threshold,
+// or of every called method with -baseline-factor. Methods not called by the
+// relist count as healthy.
+func notifyMethods(c clock, rpcs map[string]*methodStats) {
+	now := c.Now()
+	var methods []string
+	for method := range methodThresholds {
+		methods = append(methods, method)
+	}
+	if baselineFactor > 0 {
+		for method := range rpcs {
+			if _, found := methodThresholds[method]; !found {
+				methods = append(methods, method)
+			}
+		}
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		t, found := methodThresholds[method]
+		if !found {
+			t = &methodThreshold{Severity: "warning"}
+		}
+		a := &alert{
+			Node:      nodeName,
+			Check:     method,
+			Time:      now,
+			Severity:  t.Severity,
+			Threshold: t.Threshold,
+		}
+		if m, found := rpcs[method]; found {
+			// The slowest call breaches, hedged or not.
+			slowest := m.Slowest()
+			a.Threshold = learnThreshold(method, t.Threshold, a.Time, slowest)
+			a.Duration, a.Calls = slowest, m.AllCalls()
+			a.Breached = a.Threshold > 0 && slowest > a.Threshold
+		}
+		notify(c, a)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"k8s.io/klog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	topInterval = 2 * time.Second
+	// topHistory is the number of relists of the duration trend.
+	topHistory = 60
+	topEvents  = 10
+	topLogs    = 5
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// tailWriter keeps the last lines written to it.
+type tailWriter struct {
+	mu    sync.Mutex
+	max   int
+	lines []string
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.lines = append(w.lines, line)
+	}
+	if len(w.lines) > w.max {
+		w.lines = w.lines[len(w.lines)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) tail() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.lines...)
+}
+
+// top relists every interval and redraws a dashboard of the relist duration
+// trend, per-method latencies, pod counts and recent PLEG events.
+func top(rs *runtimeService, interval time.Duration) {
+	// Logging would garble the screen, show the last warnings and errors instead.
+	logs := &tailWriter{max: topLogs}
+	klog.SetOutputBySeverity("INFO", ioutil.Discard)
+	klog.SetOutputBySeverity("WARNING", logs)
+	klog.SetOutputBySeverity("ERROR", ioutil.Discard)
+	klog.SetOutputBySeverity("FATAL", ioutil.Discard)
+
+	var durations []time.Duration
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result := relist(rs)
+		if result.Err != nil {
+			klog.Errorf("Relist failed: %v", result.Err)
+		}
+		durations = append(durations, result.Duration)
+		if len(durations) > topHistory {
+			durations = durations[len(durations)-topHistory:]
+		}
+		renderTop(os.Stdout, interval, result, durations, logs.tail())
+		<-ticker.C
+	}
+}
+
+func renderTop(out io.Writer, interval time.Duration, result *relistResult, durations []time.Duration, logs []string) {
+	// Move the cursor home and clear the screen.
+	fmt.Fprint(out, "\033[H\033[2J")
+	fmt.Fprintf(out, "oncepleg top - %s - %s - %s, every %v\n\n", nodeName, remoteRuntimeEndpoint, result.Time.Format("15:04:05"), interval)
+
+	min, max, total := durations[0], durations[0], time.Duration(0)
+	for _, d := range durations {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		total += d
+	}
+	fmt.Fprintf(out, "Relist  last: %v  min: %v  avg: %v  max: %v\n", result.Duration, min, total/time.Duration(len(durations)), max)
+	fmt.Fprintf(out, "Phases  list: %v  status: %v\n", result.ListDuration, result.StatusDuration)
+	fmt.Fprintf(out, "Trend   %s\n", sparkline(durations, max))
+	fmt.Fprintf(out, "Pods: %d  Containers: %d  Events: %d\n\n", len(result.Pods), result.Containers, len(result.Events))
+
+	methods := make([]string, 0, len(result.RPCs))
+	for method := range result.RPCs {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool { return result.RPCs[methods[i]].Total > result.RPCs[methods[j]].Total })
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tCALLS\tERRORS\tAVG\tMAX\tTOTAL")
+	for _, method := range methods {
+		m := result.RPCs[method]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\t%v\t%v\n", method, m.Calls, m.Errors, m.Avg(), m.Max, m.Total)
+		if h := m.Hedged; h != nil {
+			fmt.Fprintf(w, "%s (hedged)\t%d\t%d\t%v\t%v\t%v\n", method, h.Calls, h.Errors, h.Avg(), h.Max, h.Total)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\nRECENT EVENTS")
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, e := range getRecentEvents(topEvents) {
+		fmt.Fprintf(w, "%s\t%s\tpod %s\tcontainer %s\n", e.Time.Format("15:04:05"), e.Type, e.PodID, e.ContainerID)
+	}
+	w.Flush()
+
+	if len(logs) > 0 {
+		fmt.Fprintln(out, "\nLOGS")
+		for _, line := range logs {
+			fmt.Fprintln(out, line)
+		}
+	}
+}
+
+// sparkline renders the durations relative to max.
+func sparkline(durations []time.Duration, max time.Duration) string {
+	var b strings.Builder
+	for _, d := range durations {
+		i := 0
+		if max > 0 {
+			i = int(int64(d) * int64(len(sparks)-1) / int64(max))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
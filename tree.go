@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// treeAge is the age of a sandbox or container at the time of the relist.
+func treeAge(result *relistResult, created time.Time) string {
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	return result.Time.Sub(created).Round(time.Second).String()
+}
+
+// printTree writes every pod of the relist with its sandboxes and the
+// containers nested in the sandbox they run in, so a sandbox issue shows
+// next to the containers it affects.
+func printTree(out io.Writer, result *relistResult) {
+	for _, pod := range result.Pods {
+		fmt.Fprintf(out, "%s/%s (%s)\n", redacted("ns", pod.Namespace), redacted("pod", pod.Name), pod.ID)
+
+		sandboxes := append([]*sandboxResult(nil), pod.Sandboxes...)
+		// The latest sandbox first, like the kubelet picks it.
+		sort.Slice(sandboxes, func(i, j int) bool { return sandboxes[i].CreatedAt.After(sandboxes[j].CreatedAt) })
+		containers := make(map[string][]*containerResult)
+		for _, c := range pod.Containers {
+			containers[c.SandboxID] = append(containers[c.SandboxID], c)
+		}
+		type branch struct {
+			label      string
+			containers []*containerResult
+		}
+		var branches []branch
+		for _, s := range sandboxes {
+			label := fmt.Sprintf("sandbox %s  %s  attempt %d  age %s  status %s", s.ID, colorState(s.State), s.Attempt, treeAge(result, s.CreatedAt), formatDuration(s.Latency))
+			branches = append(branches, branch{label, containers[s.ID]})
+			delete(containers, s.ID)
+		}
+		// Containers of sandboxes which were not listed.
+		var missing []string
+		for id := range containers {
+			missing = append(missing, id)
+		}
+		sort.Strings(missing)
+		for _, id := range missing {
+			branches = append(branches, branch{fmt.Sprintf("sandbox %s  not listed", id), containers[id]})
+		}
+
+		for i, b := range branches {
+			last := i == len(branches)-1
+			fmt.Fprintf(out, "%s%s\n", treeBranch(last), b.label)
+			indent := treeIndent(last)
+			if len(b.containers) == 0 {
+				fmt.Fprintf(out, "%s└── (no containers)\n", indent)
+			}
+			for j, c := range b.containers {
+				fmt.Fprintf(out, "%s%scontainer %s %s  %s  attempt %d  age %s  status %s\n", indent, treeBranch(j == len(b.containers)-1), c.ID, c.Name, colorState(c.State), c.Attempt, treeAge(result, c.CreatedAt), formatDuration(c.Latency))
+			}
+		}
+	}
+}
+
+func treeBranch(last bool) string {
+	if last {
+		return "└── "
+	}
+	return "├── "
+}
+
+func treeIndent(last bool) string {
+	if last {
+		return "    "
+	}
+	return "│   "
+}
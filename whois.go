@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// whois resolves a possibly truncated container or sandbox ID to its pod and
+// state. A prefix matching several IDs prints all of them.
+func whois(rs *runtimeService, prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("usage: oncepleg whois <id-prefix>")
+	}
+	items, err := rs.listNodeItems()
+	if err != nil {
+		return err
+	}
+	found := []*nodeItem{}
+	for _, item := range items {
+		if strings.HasPrefix(item.ID, prefix) {
+			found = append(found, item)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("no container or sandbox ID starts with %s", prefix)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+	if len(found) > 1 {
+		fmt.Printf("%d IDs start with %s\n\n", len(found), prefix)
+	}
+	for _, item := range found {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\t%s\n", strings.Title(item.Kind), item.ID)
+		if item.Kind == "container" {
+			fmt.Fprintf(w, "Name\t%s\n", item.Name)
+			fmt.Fprintf(w, "Sandbox\t%s\n", item.SandboxID)
+		}
+		fmt.Fprintf(w, "Pod\t%s/%s\n", item.Namespace, item.Pod)
+		fmt.Fprintf(w, "Pod UID\t%s\n", item.PodUID)
+		fmt.Fprintf(w, "State\t%s\n", colorState(item.State))
+		if item.Image != "" {
+			fmt.Fprintf(w, "Image\t%s\n", item.Image)
+		}
+		fmt.Fprintf(w, "Created\t%s\n", formatTime(item.CreatedAt))
+		w.Flush()
+		fmt.Println()
+	}
+	return nil
+}